@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/peng225/any-exporter/backfill"
+	"github.com/peng225/any-exporter/loader"
+)
+
+// parseStart parses either an RFC 3339 time or a duration relative to now such as "-1h".
+func parseStart(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time: %s", s)
+	}
+	return now.Add(d), nil
+}
+
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	var recipes stringsFlag
+	fs.Var(&recipes, "recipe", "recipe file, directory or glob pattern to render (can be repeated)")
+	start := fs.String("start", "-1h", "time of the first step in RFC 3339 or as a duration relative to now")
+	interval := fs.Duration("interval", time.Minute, "interval between the steps")
+	steps := fs.Int("steps", 0, "number of steps to render (0 renders until all of the sequences are exhausted)")
+	output := fs.String("output", "-", "output file ('-' for the standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(recipes) == 0 {
+		return errors.New("no recipe is specified")
+	}
+	startTime, err := parseStart(*start, time.Now())
+	if err != nil {
+		return err
+	}
+	data, err := loader.ReadAll(recipes)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return backfill.WriteOpenMetrics(w, data, startTime, *interval, *steps)
+}
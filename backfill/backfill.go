@@ -0,0 +1,98 @@
+package backfill
+
+import (
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// family accumulates the samples of a metric family across the steps.
+// The samples are grouped by series, because OpenMetrics does not allow
+// the samples of a series to be interleaved with the others.
+type family struct {
+	mf     *dto.MetricFamily
+	keys   []string
+	series map[string][]*dto.Metric
+}
+
+func seriesKey(m *dto.Metric) string {
+	var sb strings.Builder
+	for _, lp := range m.GetLabel() {
+		sb.WriteString(lp.GetName())
+		sb.WriteByte('=')
+		sb.WriteString(lp.GetValue())
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// WriteOpenMetrics simulates the recipes from start and writes all of the samples
+// in the OpenMetrics text format with their timestamps, which can be turned into
+// TSDB blocks by `promtool tsdb create-blocks-from openmetrics`.
+// The samples without an explicit timestamp in the recipe are stamped with the time of the step,
+// and the samples whose timestamp does not advance are dropped.
+// A counter whose name does not end with "_total" is written as unknown,
+// because OpenMetrics requires the suffix for a counter.
+// If steps is 0, the simulation runs until all of the sequences are exhausted.
+func WriteOpenMetrics(w io.Writer, recipes [][]byte, start time.Time, interval time.Duration, steps int) error {
+	names := make([]string, 0)
+	families := make(map[string]*family)
+	err := exporter.Simulate(recipes, start, interval, steps, func(now time.Time, mfs []*dto.MetricFamily) error {
+		for _, mf := range mfs {
+			f, ok := families[mf.GetName()]
+			if !ok {
+				f = &family{
+					mf: &dto.MetricFamily{
+						Name: mf.Name,
+						Help: mf.Help,
+						Type: mf.Type,
+					},
+					series: make(map[string][]*dto.Metric),
+				}
+				families[mf.GetName()] = f
+				names = append(names, mf.GetName())
+			}
+			for _, m := range mf.GetMetric() {
+				if m.TimestampMs == nil {
+					ts := now.UnixMilli()
+					m.TimestampMs = &ts
+				}
+				key := seriesKey(m)
+				series, ok := f.series[key]
+				if !ok {
+					f.keys = append(f.keys, key)
+				}
+				// An explicit timestamp may stay the same after its sequence is exhausted.
+				// Such a sample is dropped because the samples of a series must be in time order.
+				if len(series) != 0 && m.GetTimestampMs() <= series[len(series)-1].GetTimestampMs() {
+					continue
+				}
+				f.series[key] = append(series, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		f := families[name]
+		if f.mf.GetType() == dto.MetricType_COUNTER && !strings.HasSuffix(name, "_total") {
+			log.Printf("%s is written as unknown because its name does not end with _total", name)
+		}
+		for _, key := range f.keys {
+			f.mf.Metric = append(f.mf.Metric, f.series[key]...)
+		}
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, f.mf); err != nil {
+			return err
+		}
+	}
+	_, err = expfmt.FinalizeOpenMetrics(w)
+	return err
+}
@@ -0,0 +1,90 @@
+package backfill
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: test_counter_total
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2
+- labels:
+  - key: aaa
+    value: bar
+  sequence: 5 5 5
+---
+spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 3
+  timestamps:
+  - 2023-01-01T00:00:00Z
+`
+
+func TestWriteOpenMetrics(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOpenMetrics(&buf, [][]byte{[]byte(recipe)}, start, time.Minute, 0))
+	assert.Equal(t, []string{
+		"# HELP test_counter ",
+		"# TYPE test_counter counter",
+		`test_counter_total{aaa="bar"} 5.0 1.7040672e+09`,
+		`test_counter_total{aaa="bar"} 10.0 1.70406726e+09`,
+		`test_counter_total{aaa="bar"} 15.0 1.70406732e+09`,
+		`test_counter_total{aaa="foo"} 1.0 1.7040672e+09`,
+		`test_counter_total{aaa="foo"} 3.0 1.70406726e+09`,
+		`test_counter_total{aaa="foo"} 3.0 1.70406732e+09`,
+		"# HELP test_gauge ",
+		"# TYPE test_gauge gauge",
+		// the explicit timestamp does not advance after the sequence is exhausted
+		`test_gauge{aaa="foo"} 3.0 1.6725312e+09`,
+		"# EOF",
+	}, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"))
+
+	// the number of steps is limited
+	buf.Reset()
+	require.NoError(t, WriteOpenMetrics(&buf, [][]byte{[]byte(recipe)}, start, time.Minute, 1))
+	assert.Equal(t, 2+2+2+1+1, strings.Count(buf.String(), "\n"))
+
+	// a counter without the _total suffix loses its type
+	buf.Reset()
+	require.NoError(t, WriteOpenMetrics(&buf, [][]byte{[]byte(`spec:
+  name: test_counter
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1
+`)}, start, time.Minute, 0))
+	assert.Equal(t, []string{
+		"# HELP test_counter ",
+		"# TYPE test_counter unknown",
+		`test_counter{aaa="foo"} 1.0 1.7040672e+09`,
+		"# EOF",
+	}, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"))
+
+	assert.Error(t, WriteOpenMetrics(&buf, [][]byte{[]byte(recipe)}, start, 0, 1))
+	assert.Error(t, WriteOpenMetrics(&buf, nil, start, time.Minute, 1))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/peng225/any-exporter/client"
+)
+
+// clientFlags defines the flags common to the subcommands which drive a running server.
+func clientFlags(fs *flag.FlagSet) func() *client.Client {
+	server := fs.String("server", "http://localhost:8080", "URL of the any-exporter server")
+	workspace := fs.String("workspace", "", "workspace to operate on (the default registry if empty)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of a request")
+	return func() *client.Client {
+		return &client.Client{
+			URL:       strings.TrimSuffix(*server, "/"),
+			Workspace: *workspace,
+			HTTPClient: &http.Client{
+				Timeout: *timeout,
+			},
+		}
+	}
+}
+
+func runPost(args []string) error {
+	fs := flag.NewFlagSet("post", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: post [flags] <file>...")
+		fs.PrintDefaults()
+	}
+	newClient := clientFlags(fs)
+	format := fs.String("format", "", "recipe format (yaml, json, csv, promtool or query_range). Detected from the file extension if empty")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("no recipe file is specified")
+	}
+	c := newClient()
+	for _, file := range files {
+		if err := postFile(c, file, *format); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		fmt.Printf("%s: registered\n", file)
+	}
+	return nil
+}
+
+func postFile(c *client.Client, file, format string) error {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".json":
+			format = "json"
+		case ".csv":
+			format = "csv"
+		default:
+			format = "yaml"
+		}
+	}
+
+	contentType := "application/yaml"
+	var query url.Values
+	switch format {
+	case "yaml":
+	case "json":
+		contentType = "application/json"
+	case "csv":
+		contentType = "text/csv"
+	case "promtool", "query_range":
+		query = url.Values{"format": []string{format}}
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Post(context.Background(), f, contentType, query)
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	asYAML := fs.Bool("yaml", false, "print the registered recipes in YAML instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c := newClient()
+	if *asYAML {
+		recipes, err := c.Recipes(context.Background())
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(recipes)
+		return err
+	}
+
+	status, err := c.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(status.Metrics) == 0 {
+		fmt.Println("no recipe is registered")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tSERIES")
+	for _, m := range status.Metrics {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", m.Name, m.Type, m.Series)
+	}
+	return w.Flush()
+}
+
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: delete [flags] <name>...")
+		fs.PrintDefaults()
+	}
+	newClient := clientFlags(fs)
+	names, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		return errors.New("no metrics name is specified")
+	}
+	c := newClient()
+	for _, name := range names {
+		if err := c.Delete(context.Background(), name); err != nil {
+			var statusErr *client.StatusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%s: not registered", name)
+			}
+			return err
+		}
+		fmt.Printf("%s: deleted\n", name)
+	}
+	return nil
+}
+
+func runClear(args []string) error {
+	fs := flag.NewFlagSet("clear", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	force := fs.Bool("force", false, "delete all of the recipes, including those which still have data to export")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c := newClient()
+	before, err := c.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := c.Clear(context.Background(), *force); err != nil {
+		return err
+	}
+	after, err := c.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	remaining := make(map[string]bool)
+	for _, m := range after.Metrics {
+		remaining[m.Name] = true
+	}
+	for _, m := range before.Metrics {
+		if !remaining[m.Name] {
+			fmt.Printf("%s: deleted\n", m.Name)
+		}
+	}
+	if len(after.Metrics) != 0 {
+		fmt.Printf("%d recipe(s) still have data to export. Use --force to delete them.\n", len(after.Metrics))
+	}
+	return nil
+}
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c := newClient()
+	status, err := c.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	exhausted := 0
+	for _, m := range status.Metrics {
+		if m.Exhausted {
+			exhausted++
+		}
+	}
+	fmt.Printf("server: %s\n", c.URL)
+	if c.Workspace != "" {
+		fmt.Printf("workspace: %s\n", c.Workspace)
+	}
+	fmt.Printf("metrics: %d (%d exhausted)\n", len(status.Metrics), exhausted)
+	if status.Paused {
+		fmt.Println("paused: all metrics")
+	}
+	if len(status.Metrics) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tSERIES\tSTEPS\tEXHAUSTED\tPAUSED")
+	for _, m := range status.Metrics {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%v\t%v\n", m.Name, m.Type, m.Series, m.Steps, m.Exhausted, m.Paused || status.Paused)
+	}
+	return w.Flush()
+}
+
+func runScrape(args []string) error {
+	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	openMetrics := fs.Bool("openmetrics", false, "request the OpenMetrics format, in which exemplars are exposed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	metrics, err := newClient().Scrape(context.Background(), *openMetrics)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(metrics)
+	return err
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/peng225/any-exporter/web"
+	"github.com/prometheus/common/expfmt"
+)
+
+// StatusError is returned when the server responds with an unexpected status code.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Client drives an any-exporter server through its HTTP API.
+type Client struct {
+	// URL is the base URL of the server such as http://localhost:8080.
+	URL string
+	// Workspace scopes the requests to the workspace if it is not empty.
+	Workspace  string
+	HTTPClient *http.Client
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values,
+	header http.Header, body io.Reader) ([]byte, error) {
+	if c.Workspace != "" {
+		path = web.WorkspacePrefix + url.PathEscape(c.Workspace) + path
+	}
+	u := c.URL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("User-Agent", "any-exporter")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Method: method, Path: path, StatusCode: resp.StatusCode}
+	}
+	return respBody, nil
+}
+
+// Post posts a recipe. contentType selects the recipe format as well as query,
+// which is the query parameters of POST /recipe such as format.
+func (c *Client) Post(ctx context.Context, body io.Reader, contentType string, query url.Values) error {
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	_, err := c.do(ctx, http.MethodPost, "/recipe", query, header, body)
+	return err
+}
+
+// Recipes returns the registered recipes in YAML.
+func (c *Client) Recipes(ctx context.Context) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, "/recipe", nil, nil, nil)
+}
+
+// Delete deletes the recipe of the metrics.
+func (c *Client) Delete(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/recipe/"+url.PathEscape(name), nil, nil, nil)
+	return err
+}
+
+// Clear deletes the recipes which have no data to export anymore, or all of them if force is true.
+func (c *Client) Clear(ctx context.Context, force bool) error {
+	var query url.Values
+	if force {
+		query = url.Values{"force": []string{"true"}}
+	}
+	_, err := c.do(ctx, http.MethodDelete, "/recipe", query, nil, nil)
+	return err
+}
+
+// Status returns the state of the server.
+func (c *Client) Status(ctx context.Context) (*web.Status, error) {
+	body, err := c.do(ctx, http.MethodGet, "/status", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var status web.Status
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Scrape fetches the metrics once, in the OpenMetrics format if openMetrics is true.
+// Note that a scrape advances the sequences like that of Prometheus does.
+func (c *Client) Scrape(ctx context.Context, openMetrics bool) ([]byte, error) {
+	header := make(http.Header)
+	if openMetrics {
+		header.Set("Accept", string(expfmt.FmtOpenMetrics_1_0_0))
+	} else {
+		header.Set("Accept", string(expfmt.FmtText))
+	}
+	return c.do(ctx, http.MethodGet, "/metrics", nil, header, nil)
+}
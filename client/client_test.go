@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: test_counter
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2
+---
+spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 5 3 1
+`
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", web.MetricsHandler{
+		Exporter:     e,
+		ChildHandler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	})
+	mux.Handle("/recipe", web.RecipeHandler{Exporter: e})
+	mux.Handle("/recipe/", http.StripPrefix("/recipe/", web.RecipeItemHandler{Exporter: e}))
+	mux.Handle("/status", web.StatusHandler{Exporter: e})
+	mux.Handle(web.WorkspacePrefix, web.NewWorkspaceHandler(0, true))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	for _, workspace := range []string{"", "test"} {
+		t.Run("workspace="+workspace, func(t *testing.T) {
+			server := newServer(t)
+			c := &Client{URL: server.URL, Workspace: workspace}
+			ctx := context.Background()
+
+			require.NoError(t, c.Post(ctx, strings.NewReader(recipe), "application/yaml", nil))
+			var statusErr *StatusError
+			err := c.Post(ctx, strings.NewReader(recipe), "application/yaml", nil)
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, http.StatusConflict, statusErr.StatusCode)
+
+			recipes, err := c.Recipes(ctx)
+			require.NoError(t, err)
+			assert.Contains(t, string(recipes), "name: test_counter")
+			assert.Contains(t, string(recipes), "name: test_gauge")
+
+			metrics, err := c.Scrape(ctx, false)
+			require.NoError(t, err)
+			assert.Contains(t, string(metrics), `test_gauge{aaa="foo"} 5`)
+			_, err = c.Scrape(ctx, false)
+			require.NoError(t, err)
+
+			status, err := c.Status(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &web.Status{
+				Metrics: []exporter.MetricsStatus{
+					{Name: "test_counter", Type: "counter", Series: 1, Steps: 2, Exhausted: true},
+					{Name: "test_gauge", Type: "gauge", Series: 1, Steps: 2, Exhausted: false},
+				},
+			}, status)
+
+			require.NoError(t, c.Delete(ctx, "test_gauge"))
+			err = c.Delete(ctx, "test_gauge")
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+			require.NoError(t, c.Clear(ctx, false))
+			status, err = c.Status(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, status.Metrics)
+			assert.False(t, status.Exhausted)
+
+			require.NoError(t, c.Post(ctx, strings.NewReader(recipe), "application/yaml", nil))
+			require.NoError(t, c.Clear(ctx, true))
+			status, err = c.Status(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, status.Metrics)
+		})
+	}
+}
+
+func TestClientUnknownWorkspace(t *testing.T) {
+	server := newServer(t)
+	c := &Client{URL: server.URL, Workspace: "unknown"}
+
+	_, err := c.Status(context.Background())
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
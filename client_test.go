@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTrailingFlags(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "recipe.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("spec: {}\n"), 0o644))
+
+	// The flags after the positional arguments are applied as well.
+	require.NoError(t, runPost([]string{file, "--server", server.URL, "--workspace", "ws"}))
+	require.NoError(t, runDelete([]string{"foo", "--server", server.URL, "bar", "--workspace", "ws"}))
+	assert.Equal(t, []string{
+		"POST /ws/ws/recipe",
+		"DELETE /ws/ws/recipe/foo",
+		"DELETE /ws/ws/recipe/bar",
+	}, requests)
+}
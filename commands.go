@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// command is a subcommand run as `any-exporter <name> [flags]`.
+// Without a subcommand, any-exporter runs as a server.
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{
+		name:        "backfill",
+		description: "render recipes into OpenMetrics text with timestamps for backfilling",
+		run:         runBackfill,
+	},
+	{
+		name:        "input-series",
+		description: "render recipes into the input_series of a promtool unit test",
+		run:         runInputSeries,
+	},
+	{
+		name:        "import",
+		description: "convert a file in another format into recipes",
+		run:         runImport,
+	},
+	{
+		name:        "record",
+		description: "scrape a target several times and save the result as recipes",
+		run:         runRecord,
+	},
+	{
+		name:        "test",
+		description: "check the PromQL expectations in recipes with the embedded PromQL engine",
+		run:         runTest,
+	},
+	{
+		name:        "simulate",
+		description: "evaluate Prometheus rules against recipes and print the alert timeline",
+		run:         runSimulate,
+	},
+	{
+		name:        "post",
+		description: "post recipe files to a running server",
+		run:         runPost,
+	},
+	{
+		name:        "list",
+		description: "list the recipes registered to a running server",
+		run:         runList,
+	},
+	{
+		name:        "delete",
+		description: "delete a recipe from a running server",
+		run:         runDelete,
+	},
+	{
+		name:        "clear",
+		description: "delete the exhausted recipes (or all of them with --force) from a running server",
+		run:         runClear,
+	},
+	{
+		name:        "status",
+		description: "show the state of a running server",
+		run:         runStatus,
+	},
+	{
+		name:        "scrape",
+		description: "fetch the metrics from a running server once",
+		run:         runScrape,
+	},
+	{
+		name:        "render",
+		description: "print what a scrape gets at each step of recipes without a server",
+		run:         runRender,
+	},
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// parseInterspersed parses the flags which may be interspersed with the positional arguments
+// such as `render recipe.yaml --steps 10`, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(w, "\nWithout a command, %s runs as a server with the following flags:\n", filepath.Base(os.Args[0]))
+}
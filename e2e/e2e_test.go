@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"bytes"
 	"io"
 	"net/http"
 	"os"
@@ -21,10 +22,20 @@ func cleanUp(t *testing.T) {
 	deleteMetrics(t, true)
 }
 
+func workspaceURL(name string) string {
+	return baseURL + "/ws/" + name
+}
+
 func getMetrics(t *testing.T) string {
 	t.Helper()
 
-	resp, err := http.Get(baseURL + "/metrics")
+	return getMetricsFrom(t, baseURL)
+}
+
+func getMetricsFrom(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url + "/metrics")
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 	metricsByte, err := io.ReadAll(resp.Body)
@@ -34,14 +45,37 @@ func getMetrics(t *testing.T) string {
 	return string(metricsByte)
 }
 
+func getOpenMetrics(t *testing.T) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/metrics", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "application/openmetrics-text"))
+	metricsByte, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return string(metricsByte)
+}
+
 func postMetrics(t *testing.T, recipeFileName string, expectedStatus int) {
 	t.Helper()
 
+	postMetricsTo(t, baseURL, recipeFileName, expectedStatus)
+}
+
+func postMetricsTo(t *testing.T, url, recipeFileName string, expectedStatus int) {
+	t.Helper()
+
 	f, err := os.Open(recipeFileName)
 	require.NoError(t, err)
 	defer f.Close()
 
-	resp, err := http.Post(baseURL+"/recipe", "application/yaml", f)
+	resp, err := http.Post(url+"/recipe", "application/yaml", f)
 	require.NoError(t, err)
 	require.Equal(t, expectedStatus, resp.StatusCode)
 }
@@ -49,7 +83,13 @@ func postMetrics(t *testing.T, recipeFileName string, expectedStatus int) {
 func deleteMetrics(t *testing.T, force bool) {
 	t.Helper()
 
-	req, err := http.NewRequest(http.MethodDelete, baseURL+"/recipe", nil)
+	deleteMetricsFrom(t, baseURL, force)
+}
+
+func deleteMetricsFrom(t *testing.T, url string, force bool) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodDelete, url+"/recipe", nil)
 	require.NoError(t, err)
 	if force {
 		q := req.URL.Query()
@@ -151,3 +191,354 @@ func TestHistogram(t *testing.T) {
 
 	cleanUp(t)
 }
+
+func TestWorkspace(t *testing.T) {
+	ws1 := workspaceURL("ws1")
+	ws2 := workspaceURL("ws2")
+
+	// scraping an unknown workspace fails
+	resp, err := http.Get(workspaceURL("unknown") + "/metrics")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// an invalid recipe does not create a workspace
+	resp, err = http.Post(workspaceURL("invalid")+"/recipe", "application/yaml", strings.NewReader("spec: ["))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Get(workspaceURL("invalid") + "/metrics")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the same recipe can be posted to each workspace
+	postMetricsTo(t, ws1, "counter-and-gauge.yaml", http.StatusOK)
+	postMetricsTo(t, ws2, "counter-and-gauge.yaml", http.StatusOK)
+	postMetricsTo(t, ws1, "counter-and-gauge.yaml", http.StatusConflict)
+
+	// the default workspace is not affected
+	metrics := getMetrics(t)
+	assert.False(t, strings.Contains(metrics, "test1"), metrics)
+
+	// each workspace has its own cursor
+	metrics = getMetricsFrom(t, ws1)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4`), metrics)
+	metrics = getMetricsFrom(t, ws1)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 9`), metrics)
+	metrics = getMetricsFrom(t, ws2)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4`), metrics)
+
+	// force delete in a workspace does not affect the others
+	deleteMetricsFrom(t, ws1, true)
+	metrics = getMetricsFrom(t, ws1)
+	assert.False(t, strings.Contains(metrics, "test1"), metrics)
+	metrics = getMetricsFrom(t, ws2)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 9`), metrics)
+
+	deleteMetricsFrom(t, ws2, true)
+}
+
+func TestExemplar(t *testing.T) {
+	postMetrics(t, "exemplar.yaml", http.StatusOK)
+
+	// get metrics 1
+	metrics := getOpenMetrics(t)
+	assert.True(t, strings.Contains(metrics, `test4_total{aaa="aaa_val1"} 1.0 # {trace_id="trace1"} 1.0`), metrics)
+	assert.True(t, strings.Contains(metrics, `test5_bucket{aaa="aaa_val1",le="1.0"} 1`+"\n"), metrics)
+	assert.True(t, strings.Contains(metrics, `test5_bucket{aaa="aaa_val1",le="2.0"} 2 # {trace_id="trace4"} 1.5`), metrics)
+
+	// get metrics 2 (the exemplar of the previous step remains)
+	metrics = getOpenMetrics(t)
+	assert.True(t, strings.Contains(metrics, `test4_total{aaa="aaa_val1"} 3.0 # {trace_id="trace1"} 1.0`), metrics)
+
+	// get metrics 3
+	metrics = getOpenMetrics(t)
+	assert.True(t, strings.Contains(metrics, `test4_total{aaa="aaa_val1"} 6.0 # {trace_id="trace3"} 3.0`), metrics)
+
+	// exemplars are not exposed in the text format
+	metrics = getMetrics(t)
+	assert.False(t, strings.Contains(metrics, "trace_id"), metrics)
+
+	// a counter whose name does not end with _total is exposed as unknown in the OpenMetrics format
+	postMetrics(t, "counter-and-gauge.yaml", http.StatusOK)
+	metrics = getOpenMetrics(t)
+	assert.True(t, strings.Contains(metrics, "# TYPE test4 counter\n"), metrics)
+	assert.True(t, strings.Contains(metrics, "# TYPE test1 unknown\n"), metrics)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4.0`+"\n"), metrics)
+
+	cleanUp(t)
+}
+
+func TestRecipeGet(t *testing.T) {
+	ws := workspaceURL("recipe-get")
+	postMetricsTo(t, ws, "counter-and-gauge.yaml", http.StatusOK)
+
+	get := func(query string) string {
+		t.Helper()
+
+		resp, err := http.Get(ws + "/recipe" + query)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	// the registered recipes
+	recipes := get("")
+	assert.True(t, strings.Contains(recipes, "name: test1"), recipes)
+	assert.True(t, strings.Contains(recipes, "name: test2"), recipes)
+
+	// the promtool input series are rendered from the beginning
+	getMetricsFrom(t, ws)
+	series := get("?format=promtool&interval=30s")
+	assert.True(t, strings.Contains(series, "interval: 30s\n"), series)
+	assert.True(t, strings.Contains(series,
+		"- series: test1{aaa=\"aaa_val1\",bbb=\"bbb_val1\"}\n  values: 4 9 15 18 18 18 18 18 18\n"), series)
+	assert.True(t, strings.Contains(series,
+		"- series: test2{aaa=\"aaa_val2\",ccc=\"ccc_val1\"}\n  values: 0 1 0 0 0 0 0 0 0\n"), series)
+
+	resp, err := http.Get(ws + "/recipe?format=unknown")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	deleteMetricsFrom(t, ws, true)
+}
+
+func TestPromtoolImport(t *testing.T) {
+	ws := workspaceURL("promtool-import")
+
+	f, err := os.Open("promtool.yaml")
+	require.NoError(t, err)
+	defer f.Close()
+	resp, err := http.Post(ws+"/recipe?format=promtool", "application/yaml", f)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	metrics := getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, "# TYPE test6_total counter"), metrics)
+	assert.True(t, strings.Contains(metrics, `test6_total{aaa="aaa_val1"} 0`), metrics)
+	assert.True(t, strings.Contains(metrics, `test7{aaa="aaa_val1"} 3`), metrics)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test6_total{aaa="aaa_val1"} 10`), metrics)
+	// the series is not exposed at the missing sample
+	assert.False(t, strings.Contains(metrics, `test7{`), metrics)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test6_total{aaa="aaa_val1"} 20`), metrics)
+	assert.True(t, strings.Contains(metrics, `test7{aaa="aaa_val1"} 1`), metrics)
+
+	deleteMetricsFrom(t, ws, true)
+}
+
+func TestJSONRecipe(t *testing.T) {
+	ws := workspaceURL("json-recipe")
+
+	recipe := `[{"spec": {"name": "test8", "type": "gauge", "labels": ["aaa"]},
+		"data": [{"labels": [{"key": "aaa", "value": "aaa_val1"}], "sequence": "1 2"}]}]`
+	resp, err := http.Post(ws+"/recipe", "application/json", strings.NewReader(recipe))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	metrics := getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test8{aaa="aaa_val1"} 1`), metrics)
+
+	deleteMetricsFrom(t, ws, true)
+}
+
+func TestQueryRangeImport(t *testing.T) {
+	ws := workspaceURL("query-range-import")
+
+	f, err := os.Open("query-range.json")
+	require.NoError(t, err)
+	defer f.Close()
+	resp, err := http.Post(ws+"/recipe?format=query_range", "application/json", f)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	metrics := getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test9{aaa="aaa_val1"} 1`), metrics)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test9{aaa="aaa_val1"} 2`), metrics)
+	// the gap is a missing sample
+	metrics = getMetricsFrom(t, ws)
+	assert.False(t, strings.Contains(metrics, `test9{`), metrics)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test9{aaa="aaa_val1"} 3`), metrics)
+
+	deleteMetricsFrom(t, ws, true)
+
+	// too many steps
+	sparse := `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"__name__": "test9", "aaa": "aaa_val1"}, "values": [[0, "1"], [0.001, "1"], [100000000, "1"]]}]}}`
+	resp, err = http.Post(baseURL+"/recipe?format=query_range", "application/json", strings.NewReader(sparse))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	data, err := os.ReadFile("query-range.json")
+	require.NoError(t, err)
+	resp, err = http.Post(baseURL+"/recipe?format=query_range&step=1ns", "application/json", bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCSVRecipe(t *testing.T) {
+	ws := workspaceURL("csv-recipe")
+
+	f, err := os.Open("sequence.csv")
+	require.NoError(t, err)
+	defer f.Close()
+	resp, err := http.Post(ws+"/recipe", "text/csv", f)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	metrics := getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test10{aaa="aaa_val1"} 1`), metrics)
+	assert.True(t, strings.Contains(metrics, `test11{aaa="aaa_val1",bbb="bbb_val1"} 2`), metrics)
+	metrics = getMetricsFrom(t, ws)
+	assert.False(t, strings.Contains(metrics, `test10{`), metrics)
+	assert.True(t, strings.Contains(metrics, `test11{aaa="aaa_val1",bbb="bbb_val1"} 5`), metrics)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test10{aaa="aaa_val1"} 5`), metrics)
+	assert.False(t, strings.Contains(metrics, `test11{`), metrics)
+
+	deleteMetricsFrom(t, ws, true)
+}
+
+func TestRecipeDeleteByNameAndStatus(t *testing.T) {
+	ws := workspaceURL("delete-by-name")
+	postMetricsTo(t, ws, "counter-and-gauge.yaml", http.StatusOK)
+	getMetricsFrom(t, ws)
+
+	getStatus := func() string {
+		t.Helper()
+
+		resp, err := http.Get(ws + "/status")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	status := getStatus()
+	assert.True(t, strings.Contains(status, `{"name":"test1","type":"counter","series":2,"steps":1,"exhausted":false,"paused":false}`), status)
+	assert.True(t, strings.Contains(status, `"name":"test2"`), status)
+
+	deleteByName := func(name string, expectedStatus int) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodDelete, ws+"/recipe/"+name, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, expectedStatus, resp.StatusCode)
+	}
+	deleteByName("test1", http.StatusOK)
+	deleteByName("test1", http.StatusNotFound)
+
+	status = getStatus()
+	assert.False(t, strings.Contains(status, `"name":"test1"`), status)
+	assert.True(t, strings.Contains(status, `"name":"test2"`), status)
+	metrics := getMetricsFrom(t, ws)
+	assert.False(t, strings.Contains(metrics, "test1{"), metrics)
+
+	deleteMetricsFrom(t, ws, true)
+}
+
+func TestControl(t *testing.T) {
+	ws := workspaceURL("control")
+	postMetricsTo(t, ws, "counter-and-gauge.yaml", http.StatusOK)
+
+	control := func(op string, expectedStatus int) {
+		t.Helper()
+
+		resp, err := http.Post(ws+"/control/"+op, "", nil)
+		require.NoError(t, err)
+		require.Equal(t, expectedStatus, resp.StatusCode)
+	}
+
+	metrics := getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4`), metrics)
+
+	// scrapes do not advance the paused metrics
+	control("pause", http.StatusOK)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4`), metrics)
+
+	// step advances the metrics explicitly
+	control("step?n=2&name=test1", http.StatusOK)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 15`), metrics)
+	assert.True(t, strings.Contains(metrics, `test2{aaa="aaa_val2",ccc="ccc_val1"} 0`), metrics)
+
+	// rewind brings the counter value back
+	control("rewind?to=1", http.StatusOK)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4`), metrics)
+
+	control("resume", http.StatusOK)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 9`), metrics)
+
+	// reset starts the sequences over
+	control("reset?name=test1", http.StatusOK)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 4`), metrics)
+	assert.True(t, strings.Contains(metrics, `test2{aaa="aaa_val2",ccc="ccc_val1"} 0`), metrics)
+
+	control("pause?name=unknown", http.StatusNotFound)
+	control("rewind?to=abc", http.StatusBadRequest)
+	control("unknown", http.StatusNotFound)
+
+	deleteMetricsFrom(t, ws, true)
+}
+
+func TestRecipePatch(t *testing.T) {
+	ws := workspaceURL("recipe-patch")
+	postMetricsTo(t, ws, "counter-and-gauge.yaml", http.StatusOK)
+
+	patch := func(name, body string, expectedStatus int) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPatch, ws+"/recipe/"+name, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/yaml")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, expectedStatus, resp.StatusCode)
+	}
+
+	// exhaust the sequence of test1{aaa="aaa_val1",bbb="bbb_val1"}
+	var metrics string
+	for i := 0; i < 4; i++ {
+		metrics = getMetricsFrom(t, ws)
+	}
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 18`), metrics)
+
+	patch("test1", `data:
+- labels:
+  - key: aaa
+    value: aaa_val1
+  - key: bbb
+    value: bbb_val1
+  sequence: 10
+- labels:
+  - key: aaa
+    value: aaa_val1
+  - key: bbb
+    value: bbb_val3
+  sequence: 1
+`, http.StatusOK)
+	metrics = getMetricsFrom(t, ws)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val1"} 28`), metrics)
+	assert.True(t, strings.Contains(metrics, `test1{aaa="aaa_val1",bbb="bbb_val3"} 1`), metrics)
+
+	patch("test1", `data:
+- labels:
+  - key: ccc
+    value: ccc_val1
+  sequence: 1
+`, http.StatusBadRequest)
+	patch("unknown", `data: []`, http.StatusNotFound)
+
+	deleteMetricsFrom(t, ws, true)
+}
@@ -0,0 +1,221 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: test_counter_total
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 0 60x10
+- labels:
+  - key: aaa
+    value: bar
+  sequence: 0 _ 6x5
+---
+spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2 _ 4
+`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		expectations string
+		pass         []bool
+	}{
+		{
+			name: "vector",
+			expectations: `
+  - expr: rate(test_counter_total[5m])
+    step: 8
+    samples:
+    - labels: '{aaa="foo"}'
+      value: 1
+    - labels: '{aaa="bar"}'
+      value: 0.1
+  - expr: test_counter_total{aaa="foo"}
+    step: 8
+    samples:
+    - labels: 'test_counter_total{aaa="foo"}'
+      value: 420
+`,
+			pass: []bool{true, true},
+		},
+		{
+			name: "missing sample",
+			expectations: `
+  - expr: test_counter_total{aaa="bar"}
+    step: 2
+    samples: []
+  - expr: test_gauge
+    step: 3
+    samples: []
+  - expr: test_gauge
+    step: 4
+    samples:
+    - labels: test_gauge{aaa="foo"}
+      value: 4
+`,
+			pass: []bool{true, true, true},
+		},
+		{
+			name: "scalar",
+			expectations: `
+  - expr: scalar(test_gauge) * 2
+    step: 2
+    samples:
+    - value: 4
+`,
+			pass: []bool{true},
+		},
+		{
+			name: "mismatch",
+			expectations: `
+  - expr: test_gauge
+    step: 1
+    samples:
+    - labels: test_gauge{aaa="foo"}
+      value: 2
+  - expr: test_gauge
+    step: 1
+    samples:
+    - labels: test_gauge{aaa="bar"}
+      value: 1
+  - expr: test_gauge
+    step: 1
+    samples: []
+`,
+			pass: []bool{false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipes := [][]byte{[]byte(recipe + "expectations:" + tt.expectations)}
+			results, err := Run(context.Background(), t.TempDir(), recipes, time.Minute)
+			require.NoError(t, err)
+			require.Len(t, results, len(tt.pass))
+			for i, r := range results {
+				if tt.pass[i] {
+					assert.NoError(t, r.Err, r.Expectation.Expr)
+				} else {
+					assert.Error(t, r.Err, r.Expectation.Expr)
+				}
+			}
+		})
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		recipe string
+	}{
+		{
+			name:   "no expectation",
+			recipe: recipe,
+		},
+		{
+			name: "invalid step",
+			recipe: recipe + `expectations:
+- expr: test_gauge
+  step: 0
+  samples: []
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Run(context.Background(), t.TempDir(), [][]byte{[]byte(tt.recipe)}, time.Minute)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRunInvalidExpr(t *testing.T) {
+	recipes := [][]byte{[]byte(recipe + `expectations:
+- expr: rate(test_gauge
+  step: 1
+  samples: []
+`)}
+	results, err := Run(context.Background(), t.TempDir(), recipes, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Error(t, results[0].Err)
+}
+
+func TestSimulateRules(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`groups:
+- name: test
+  rules:
+  - record: aaa:test_counter:rate2m
+    expr: sum by (aaa) (rate(test_counter_total[2m]))
+  - alert: HighRate
+    expr: aaa:test_counter:rate2m{aaa="bar"} > 0.05
+    for: 2m
+  - alert: GaugeMissing
+    expr: absent(test_gauge)
+`), 0o644))
+
+	events, err := SimulateRules(context.Background(), t.TempDir(), [][]byte{[]byte(recipe)}, []string{ruleFile}, time.Minute, 10)
+	require.NoError(t, err)
+
+	highRate := labels.FromStrings(labels.AlertName, "HighRate", "aaa", "bar")
+	gaugeMissing := labels.FromStrings(labels.AlertName, "GaugeMissing")
+	expected := []AlertEvent{
+		{Step: 3, Name: "GaugeMissing", Labels: gaugeMissing, State: AlertFiring, Value: 1},
+		{Step: 4, Name: "GaugeMissing", Labels: gaugeMissing, State: AlertResolved, Value: 1},
+		{Step: 4, Name: "HighRate", Labels: highRate, State: AlertPending, Value: 0.1},
+		{Step: 6, Name: "HighRate", Labels: highRate, State: AlertFiring, Value: 0.1},
+		{Step: 9, Name: "HighRate", Labels: highRate, State: AlertResolved, Value: 0.1},
+	}
+	require.Len(t, events, len(expected))
+	for i, e := range expected {
+		e.Time = StepTime(e.Step, time.Minute)
+		assert.Equal(t, e.Step, events[i].Step)
+		assert.Equal(t, e.Time, events[i].Time)
+		assert.Equal(t, e.Name, events[i].Name)
+		assert.True(t, labels.Equal(e.Labels, events[i].Labels), events[i].Labels.String())
+		assert.Equal(t, e.State, events[i].State)
+		assert.InDelta(t, e.Value, events[i].Value, 1e-9)
+	}
+}
+
+func TestSimulateRulesInvalid(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "rules.yml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`groups:
+- name: test
+  rules:
+  - alert: Invalid
+    expr: rate(test_gauge
+`), 0o644))
+
+	_, err := SimulateRules(context.Background(), t.TempDir(), [][]byte{[]byte(recipe)}, []string{ruleFile}, time.Minute, 1)
+	assert.Error(t, err)
+	_, err = SimulateRules(context.Background(), t.TempDir(), [][]byte{[]byte(recipe)}, nil, time.Minute, 1)
+	assert.Error(t, err)
+}
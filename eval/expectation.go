@@ -0,0 +1,162 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// epsilon is the relative tolerance to compare the values.
+const epsilon = 1e-6
+
+// Result is the result of an expectation.
+type Result struct {
+	Expectation exporter.Expectation
+	// Err is nil if the expectation is met.
+	Err error
+}
+
+// NewEngine returns a PromQL engine configured like Prometheus.
+func NewEngine() *promql.Engine {
+	return promql.NewEngine(promql.EngineOpts{
+		MaxSamples:           50000000,
+		Timeout:              time.Minute,
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+	})
+}
+
+// Run simulates the recipes and checks the expectations defined in them.
+// The expressions are evaluated against the samples exported every interval
+// by the in-process PromQL engine. dir is used to store the samples.
+func Run(ctx context.Context, dir string, recipes [][]byte, interval time.Duration) ([]Result, error) {
+	var expectations []exporter.Expectation
+	steps := 0
+	for _, recipe := range recipes {
+		es, err := exporter.ParseExpectations(recipe)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range es {
+			if e.Step <= 0 {
+				return nil, fmt.Errorf("invalid step %d of %s", e.Step, e.Expr)
+			}
+			if e.Step > steps {
+				steps = e.Step
+			}
+		}
+		expectations = append(expectations, es...)
+	}
+	if len(expectations) == 0 {
+		return nil, fmt.Errorf("no expectation is defined")
+	}
+
+	db, err := Load(ctx, dir, recipes, interval, steps)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	engine := NewEngine()
+	results := make([]Result, 0, len(expectations))
+	for _, e := range expectations {
+		results = append(results, Result{
+			Expectation: e,
+			Err:         check(ctx, engine, db, e, StepTime(e.Step, interval)),
+		})
+	}
+	return results, nil
+}
+
+type resultSample struct {
+	labels labels.Labels
+	value  float64
+}
+
+func check(ctx context.Context, engine *promql.Engine, q storage.Queryable, e exporter.Expectation, ts time.Time) error {
+	query, err := engine.NewInstantQuery(ctx, q, nil, e.Expr, ts)
+	if err != nil {
+		return err
+	}
+	defer query.Close()
+	res := query.Exec(ctx)
+	if res.Err != nil {
+		return res.Err
+	}
+
+	var got []resultSample
+	switch v := res.Value.(type) {
+	case promql.Vector:
+		for _, s := range v {
+			got = append(got, resultSample{labels: s.Metric, value: s.F})
+		}
+	case promql.Scalar:
+		got = append(got, resultSample{labels: labels.EmptyLabels(), value: v.V})
+	default:
+		return fmt.Errorf("unsupported result type %s", res.Value.Type())
+	}
+
+	var expected []resultSample
+	for _, s := range e.Samples {
+		lbls := labels.EmptyLabels()
+		if s.Labels != "" {
+			lbls, err = parser.ParseMetric(s.Labels)
+			if err != nil {
+				return fmt.Errorf("invalid labels %q: %w", s.Labels, err)
+			}
+		}
+		expected = append(expected, resultSample{labels: lbls, value: s.Value})
+	}
+
+	sortSamples(got)
+	sortSamples(expected)
+	if len(got) == len(expected) {
+		matched := true
+		for i := range got {
+			if !labels.Equal(got[i].labels, expected[i].labels) || !almostEqual(got[i].value, expected[i].value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected:\n%s\ngot:\n%s", formatSamples(expected), formatSamples(got))
+}
+
+func sortSamples(samples []resultSample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return labels.Compare(samples[i].labels, samples[j].labels) < 0
+	})
+}
+
+func formatSamples(samples []resultSample) string {
+	if len(samples) == 0 {
+		return "  (empty)"
+	}
+	lines := make([]string, 0, len(samples))
+	for _, s := range samples {
+		lines = append(lines, fmt.Sprintf("  %s %v", s.labels, s.value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func almostEqual(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= epsilon*math.Max(math.Abs(a), math.Abs(b))
+}
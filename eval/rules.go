@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/tsdb"
+)
+
+// The states of an alert reported in the timeline.
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertEvent is a state change of an alert.
+type AlertEvent struct {
+	// Step is the 1-origin index of the step at which the state changed.
+	Step int
+	// Time is the time of the step.
+	Time   time.Time
+	Name   string
+	Labels labels.Labels
+	State  string
+	// Value is the value of the alerting expression. It is that of the last evaluation for a resolved alert.
+	Value float64
+}
+
+type activeAlert struct {
+	name   string
+	labels labels.Labels
+	state  string
+	value  float64
+}
+
+// SimulateRules simulates the recipes and evaluates the rule groups in ruleFiles
+// at each step with the in-process rule manager, and returns the state changes of the alerts.
+// The rules are evaluated every interval right after the samples of the step are stored,
+// and the results of the recording rules are stored as well so that the other rules can use them.
+// dir is used to store the samples. If steps is 0, the simulation runs until all of the sequences are exhausted.
+func SimulateRules(ctx context.Context, dir string, recipes [][]byte, ruleFiles []string,
+	interval time.Duration, steps int) ([]AlertEvent, error) {
+	if len(ruleFiles) == 0 {
+		return nil, errors.New("no rule file is specified")
+	}
+
+	engine := NewEngine()
+	var groups []*rules.Group
+	active := make(map[uint64]*activeAlert)
+	events := make([]AlertEvent, 0)
+	db, err := load(ctx, dir, recipes, interval, steps, func(db *tsdb.DB, step int, now time.Time) error {
+		if groups == nil {
+			var err error
+			groups, err = loadGroups(ctx, engine, db, ruleFiles, interval)
+			if err != nil {
+				return err
+			}
+		}
+
+		current := make(map[uint64]*activeAlert)
+		stepEvents := make([]AlertEvent, 0)
+		for _, g := range groups {
+			g.Eval(ctx, now)
+			for _, r := range g.Rules() {
+				if r.LastError() != nil {
+					return fmt.Errorf("rule %s at step %d: %w", r.Name(), step, r.LastError())
+				}
+				ar, ok := r.(*rules.AlertingRule)
+				if !ok {
+					continue
+				}
+				for _, a := range ar.ActiveAlerts() {
+					state := AlertPending
+					if a.State == rules.StateFiring {
+						state = AlertFiring
+					}
+					hash := a.Labels.Hash()
+					current[hash] = &activeAlert{name: ar.Name(), labels: a.Labels, state: state, value: a.Value}
+					if prev, ok := active[hash]; ok && prev.state == state {
+						continue
+					}
+					stepEvents = append(stepEvents, AlertEvent{
+						Step: step, Time: now, Name: ar.Name(), Labels: a.Labels, State: state, Value: a.Value,
+					})
+				}
+			}
+		}
+		for hash, a := range active {
+			if _, ok := current[hash]; !ok {
+				stepEvents = append(stepEvents, AlertEvent{
+					Step: step, Time: now, Name: a.name, Labels: a.labels, State: AlertResolved, Value: a.value,
+				})
+			}
+		}
+		sort.Slice(stepEvents, func(i, j int) bool {
+			if stepEvents[i].Name != stepEvents[j].Name {
+				return stepEvents[i].Name < stepEvents[j].Name
+			}
+			return labels.Compare(stepEvents[i].Labels, stepEvents[j].Labels) < 0
+		})
+		events = append(events, stepEvents...)
+		active = current
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.Close()
+	return events, nil
+}
+
+// loadGroups loads the rule groups, which are ordered by the files and then by the group names.
+func loadGroups(ctx context.Context, engine *promql.Engine, db *tsdb.DB, ruleFiles []string, interval time.Duration) ([]*rules.Group, error) {
+	m := rules.NewManager(&rules.ManagerOptions{
+		QueryFunc:  rules.EngineQueryFunc(engine, db),
+		Appendable: db,
+		Queryable:  db,
+		Context:    ctx,
+		NotifyFunc: func(ctx context.Context, expr string, alerts ...*rules.Alert) {},
+		Logger:     log.NewNopLogger(),
+	})
+	groupsMap, errs := m.LoadGroups(interval, labels.EmptyLabels(), "", nil, ruleFiles...)
+	if errs != nil {
+		return nil, errs[0]
+	}
+
+	groups := make([]*rules.Group, 0, len(groupsMap))
+	for _, g := range groupsMap {
+		groups = append(groups, g)
+	}
+	order := make(map[string]int)
+	for i, f := range ruleFiles {
+		order[f] = i
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].File() != groups[j].File() {
+			return order[groups[i].File()] < order[groups[j].File()]
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			if ar, ok := r.(*rules.AlertingRule); ok {
+				// Create the ALERTS series from the first evaluation, as nothing is restored.
+				ar.SetRestored(true)
+			}
+		}
+	}
+	return groups, nil
+}
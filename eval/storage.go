@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/sample"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+)
+
+// Start is the time of the first step of the simulation.
+var Start = time.Unix(0, 0).UTC()
+
+// StepTime returns the time of the step (1-origin).
+func StepTime(step int, interval time.Duration) time.Time {
+	return Start.Add(time.Duration(step-1) * interval)
+}
+
+// Load simulates the recipes from Start and stores the exported samples
+// into a TSDB created in dir, as if they were scraped by Prometheus every interval.
+// A stale marker is stored when a series disappears like Prometheus does.
+// The samples which cannot be stored, e.g. those whose explicit timestamp goes back, are dropped.
+func Load(ctx context.Context, dir string, recipes [][]byte, interval time.Duration, steps int) (*tsdb.DB, error) {
+	return load(ctx, dir, recipes, interval, steps, nil)
+}
+
+// load is the same as Load except that fn is called after the samples of each step are stored.
+func load(ctx context.Context, dir string, recipes [][]byte, interval time.Duration, steps int,
+	fn func(db *tsdb.DB, step int, now time.Time) error) (*tsdb.DB, error) {
+	db, err := tsdb.Open(dir, nil, nil, tsdb.DefaultOptions(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[uint64]labels.Labels)
+	step := 0
+	err = exporter.Simulate(recipes, Start, interval, steps, func(now time.Time, mfs []*dto.MetricFamily) error {
+		step++
+		app := db.Appender(ctx)
+		current := make(map[uint64]labels.Labels)
+		for _, s := range sample.FromMetricFamilies(mfs) {
+			ts := s.TimestampMs
+			if ts == 0 {
+				ts = now.UnixMilli()
+			}
+			current[s.Labels.Hash()] = s.Labels
+			if _, err := app.Append(0, s.Labels, ts, s.Value); err != nil && !droppable(err) {
+				app.Rollback()
+				return err
+			}
+		}
+		for hash, lbls := range previous {
+			if _, ok := current[hash]; !ok {
+				if _, err := app.Append(0, lbls, now.UnixMilli(), math.Float64frombits(value.StaleNaN)); err != nil && !droppable(err) {
+					app.Rollback()
+					return err
+				}
+			}
+		}
+		previous = current
+		if err := app.Commit(); err != nil {
+			return err
+		}
+		if fn != nil {
+			return fn(db, step, now)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func droppable(err error) bool {
+	return errors.Is(err, storage.ErrOutOfOrderSample) ||
+		errors.Is(err, storage.ErrDuplicateSampleForTimestamp) ||
+		errors.Is(err, storage.ErrOutOfBounds) ||
+		errors.Is(err, storage.ErrTooOldSample)
+}
@@ -0,0 +1,271 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// recipePatch is the data appended to a registered recipe.
+type recipePatch struct {
+	Data []metricsData `yaml:"data" json:"data"`
+}
+
+// Append appends the data in yamlData to the registered metrics.
+// The values of a data entry whose labels are the same as those of a registered one are appended
+// to its sequence (or observed values), and are exported after the remaining values.
+// A counter carries on from its current value. A data entry with new labels is added,
+// and is exported from the next update.
+// The data is validated in the same way as Register, e.g. its labels must match the spec.
+func (e *Exporter) Append(metricsName string, yamlData []byte) error {
+	var patch recipePatch
+	if err := yaml.Unmarshal(yamlData, &patch); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.appendData(metricsName, patch.Data)
+}
+
+// AppendJSON is the same as Append except that the data is written in JSON.
+func (e *Exporter) AppendJSON(metricsName string, jsonData []byte) error {
+	var patch recipePatch
+	if err := json.Unmarshal(jsonData, &patch); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.appendData(metricsName, patch.Data)
+}
+
+// Lock should be acquired by the caller.
+func (e *Exporter) appendData(metricsName string, data []metricsData) error {
+	exporter, ok := e.exporters[metricsName]
+	if !ok {
+		return fmt.Errorf("%s: %w", metricsName, NotFoundErr)
+	}
+	if len(data) == 0 {
+		return errors.New("no data to append")
+	}
+
+	appended := metricsRecipe{
+		Spec: exporter.recipe.Spec,
+		Data: data,
+	}
+	pmds, err := parseData(&appended)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for i, pmd := range pmds {
+		if len(pmd.sequence) == 0 && len(pmd.observedValues) == 0 {
+			return fmt.Errorf("no value to append for %v", pmd.labels)
+		}
+		key := labelMapKey(pmd.labels)
+		if seen[key] {
+			return fmt.Errorf("duplicated data labels: %v", pmds[i].labels)
+		}
+		seen[key] = true
+	}
+
+	recipe, err := appendToRecipe(&exporter.recipe, data, exporter.steps)
+	if err != nil {
+		return err
+	}
+	exporter.appendData(pmds)
+	if exporter.initial == nil {
+		initial := exporter.recipe
+		exporter.initial = &initial
+	}
+	exporter.recipe = *recipe
+	e.generation++
+
+	log.Printf("data was appended to metrics %s", metricsName)
+	return nil
+}
+
+func parseData(recipe *metricsRecipe) ([]*parsedMetricsData, error) {
+	switch strToMetricsType[recipe.Spec.Type] {
+	case Counter:
+		return parseCounterData(recipe)
+	case Gauge:
+		return parseGaugeData(recipe)
+	case Histogram:
+		return parseHistogramData(recipe)
+	default:
+		panic(fmt.Sprintf("unknown type: %s", recipe.Spec.Type))
+	}
+}
+
+func labelMapKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
+
+// appendParsedMetricsData appends the values of src to the data in dst with the same labels,
+// keeping the counter total, and adds the other data in src to dst.
+func appendParsedMetricsData(dst, src []*parsedMetricsData) []*parsedMetricsData {
+	indices := make(map[string]int)
+	for i, pmd := range dst {
+		indices[labelMapKey(pmd.labels)] = i
+	}
+	for _, pmd := range src {
+		i, ok := indices[labelMapKey(pmd.labels)]
+		if !ok {
+			dst = append(dst, pmd)
+			continue
+		}
+		d := dst[i]
+		// The exemplars and timestamps are consumed along with the values,
+		// so pad them to the remaining values before appending.
+		remaining := len(d.sequence) + len(d.observedValues)
+		if len(pmd.exemplars) != 0 {
+			for len(d.exemplars) < remaining {
+				d.exemplars = append(d.exemplars, nil)
+			}
+			d.exemplars = append(d.exemplars, pmd.exemplars...)
+		}
+		if len(pmd.timestamps) != 0 {
+			for len(d.timestamps) < remaining {
+				d.timestamps = append(d.timestamps, nil)
+			}
+			d.timestamps = append(d.timestamps, pmd.timestamps...)
+		}
+		d.sequence = append(d.sequence, pmd.sequence...)
+		d.observedValues = append(d.observedValues, pmd.observedValues...)
+	}
+	return dst
+}
+
+// appendToRecipe returns the recipe with the data appended at the given step,
+// so that replaying the recipe, e.g. by Rewind or Restore, reproduces the appended values.
+// The steps after a data entry was exhausted are filled with the values which keep the series as it was,
+// and a new data entry is preceded by the missing samples.
+func appendToRecipe(recipe *metricsRecipe, data []metricsData, steps int) (*metricsRecipe, error) {
+	result := &metricsRecipe{
+		Spec:         recipe.Spec,
+		Data:         make([]metricsData, len(recipe.Data)),
+		Expectations: recipe.Expectations,
+	}
+	copy(result.Data, recipe.Data)
+	indices := make(map[string]int)
+	for i, d := range result.Data {
+		indices[dataLabelsKey(d.Labels)] = i
+	}
+
+	histogram := strToMetricsType[recipe.Spec.Type] == Histogram
+	for _, d := range data {
+		i, ok := indices[dataLabelsKey(d.Labels)]
+		if !ok {
+			i = len(result.Data)
+			result.Data = append(result.Data, metricsData{Labels: d.Labels})
+		}
+		orig := result.Data[i]
+
+		// length is the number of the steps of the original data.
+		var length int
+		var padding string
+		if histogram {
+			length = len(orig.ObservedValues)
+		} else if orig.Sequence != "" {
+			sequence, err := parseSequence(orig.Sequence)
+			if err != nil {
+				return nil, err
+			}
+			length = len(sequence)
+			if steps > length {
+				padding = padSequence(recipe.Spec.Type, sequence[length-1], steps-length)
+			}
+		} else if steps > 0 {
+			padding = fmt.Sprintf("_x%d", steps)
+		}
+		total := length
+		if steps > length {
+			total = steps
+		}
+
+		merged := metricsData{
+			Labels:     orig.Labels,
+			Exemplars:  orig.Exemplars,
+			Timestamps: orig.Timestamps,
+		}
+		if histogram {
+			merged.ObservedValues = append(merged.ObservedValues, orig.ObservedValues...)
+			for len(merged.ObservedValues) < total {
+				merged.ObservedValues = append(merged.ObservedValues, "")
+			}
+			merged.ObservedValues = append(merged.ObservedValues, d.ObservedValues...)
+		} else {
+			merged.Sequence = strings.Join(nonEmpty(orig.Sequence, padding, d.Sequence), " ")
+		}
+		if len(d.Exemplars) != 0 {
+			merged.Exemplars = append([]exemplar{}, orig.Exemplars...)
+			for len(merged.Exemplars) < total {
+				merged.Exemplars = append(merged.Exemplars, exemplar{})
+			}
+			merged.Exemplars = append(merged.Exemplars, d.Exemplars...)
+		}
+		if len(d.Timestamps) != 0 {
+			merged.Timestamps = append([]string{}, orig.Timestamps...)
+			for len(merged.Timestamps) < total {
+				merged.Timestamps = append(merged.Timestamps, "")
+			}
+			merged.Timestamps = append(merged.Timestamps, d.Timestamps...)
+		}
+		result.Data[i] = merged
+	}
+	return result, nil
+}
+
+func dataLabelsKey(labels []label) string {
+	m := make(map[string]string)
+	for _, l := range labels {
+		m[l.Key] = l.Value
+	}
+	return labelMapKey(m)
+}
+
+// padSequence returns the sequence of n steps which keeps an exhausted series as it is.
+// A counter stays the same by adding zeros, and a gauge repeats its last value.
+func padSequence(metricsType string, last float64, n int) string {
+	if isMissing(last) {
+		return fmt.Sprintf("_x%d", n)
+	}
+	if metricsType == "counter" {
+		last = 0
+	}
+	if math.IsNaN(last) || math.IsInf(last, 0) {
+		return strings.TrimSpace(strings.Repeat(strconv.FormatFloat(last, 'f', -1, 64)+" ", n))
+	}
+	v := strconv.FormatFloat(last, 'f', -1, 64)
+	if n == 1 {
+		return v
+	}
+	// "v+0xN" is N+1 values.
+	return fmt.Sprintf("%s+0x%d", v, n-1)
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
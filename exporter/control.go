@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Lock should be acquired by the caller.
+func (e *Exporter) lookup(metricsNames []string) ([]string, error) {
+	if len(metricsNames) == 0 {
+		names := make([]string, 0, len(e.exporters))
+		for metName := range e.exporters {
+			names = append(names, metName)
+		}
+		return names, nil
+	}
+	for _, metName := range metricsNames {
+		if _, ok := e.exporters[metName]; !ok {
+			return nil, fmt.Errorf("%s: %w", metName, NotFoundErr)
+		}
+	}
+	return metricsNames, nil
+}
+
+// Pause stops Update from advancing the specified metrics.
+// If no metrics name is given, all of the metrics, including those registered later, are paused.
+func (e *Exporter) Pause(metricsNames ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(metricsNames) == 0 {
+		e.paused = true
+		log.Println("all metrics were paused")
+		return nil
+	}
+	if _, err := e.lookup(metricsNames); err != nil {
+		return err
+	}
+	for _, metName := range metricsNames {
+		e.exporters[metName].paused = true
+		log.Printf("metrics %s was paused", metName)
+	}
+	return nil
+}
+
+// Resume lets Update advance the specified metrics again.
+// If no metrics name is given, all of the metrics are resumed, including those paused by name.
+func (e *Exporter) Resume(metricsNames ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names, err := e.lookup(metricsNames)
+	if err != nil {
+		return err
+	}
+	if len(metricsNames) == 0 {
+		e.paused = false
+		log.Println("all metrics were resumed")
+	}
+	for _, metName := range names {
+		e.exporters[metName].paused = false
+		if len(metricsNames) != 0 {
+			log.Printf("metrics %s was resumed", metName)
+		}
+	}
+	return nil
+}
+
+// Paused reports whether all of the metrics are paused by Pause without a metrics name.
+func (e *Exporter) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.paused
+}
+
+// Step advances the specified metrics, or all of the metrics if no name is given, by n steps.
+// The metrics are advanced even if they are paused, but not beyond the end of their sequences.
+func (e *Exporter) Step(n int, metricsNames ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if n <= 0 {
+		return fmt.Errorf("invalid number of steps: %d", n)
+	}
+	names, err := e.lookup(metricsNames)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, metName := range names {
+		exporter := e.exporters[metName]
+		// Stop at the end so that a large n does not keep the lock for long.
+		for i := 0; i < n && !exporter.isExhausted(); i++ {
+			exporter.update(metName, now)
+			exporter.steps++
+		}
+		e.generation++
+		log.Printf("metrics %s was advanced to step %d", metName, exporter.steps)
+	}
+	return nil
+}
+
+// Rewind moves the specified metrics, or all of the metrics if no name is given, back to the step,
+// i.e. the state after the step-th update. The metrics at or before the step are not changed.
+// Since the metrics are reset and the sequences are replayed, the counter and histogram values
+// go back as well. The paused state is kept.
+func (e *Exporter) Rewind(step int, metricsNames ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if step < 0 {
+		return fmt.Errorf("invalid step: %d", step)
+	}
+	names, err := e.lookup(metricsNames)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, metName := range names {
+		if e.exporters[metName].steps <= step {
+			continue
+		}
+		if err := e.rewind(metName, step, now); err != nil {
+			return err
+		}
+		log.Printf("metrics %s was rewound to step %d", metName, step)
+	}
+	return nil
+}
+
+// Reset brings the specified metrics, or all of the metrics if no name is given, back to
+// the initial state as if they were just registered. The sequences are parsed again from
+// the recipes as they were registered, i.e. the appended data is dropped, and the values
+// are cleared in place without re-registering the metrics. The paused state is kept.
+func (e *Exporter) Reset(metricsNames ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names, err := e.lookup(metricsNames)
+	if err != nil {
+		return err
+	}
+
+	for _, metName := range names {
+		exporter := e.exporters[metName]
+		if exporter.initial != nil {
+			exporter.recipe = *exporter.initial
+			exporter.initial = nil
+		}
+		if err := e.rewind(metName, 0, time.Now()); err != nil {
+			return err
+		}
+		log.Printf("metrics %s was reset", metName)
+	}
+	return nil
+}
+
+// Lock should be acquired by the caller.
+func (e *Exporter) rewind(metName string, step int, now time.Time) error {
+	exporter := e.exporters[metName]
+	if err := exporter.reset(&exporter.recipe); err != nil {
+		return fmt.Errorf("failed to reset %s: %w", metName, err)
+	}
+	exporter.steps = 0
+	for exporter.steps < step {
+		exporter.update(metName, now)
+		exporter.steps++
+	}
+	e.generation++
+	return nil
+}
@@ -0,0 +1,225 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// csvTimestampColumn is the header of the optional column which holds the timestamp of each step.
+const csvTimestampColumn = "timestamp"
+
+// csvColumn is a series defined by a column of a CSV recipe.
+type csvColumn struct {
+	recipe   *metricsRecipe
+	data     int
+	sequence strings.Builder
+}
+
+// parseCSVHeader parses a header cell such as `name{key="value"} counter`.
+// The type is either counter or gauge, and defaults to gauge.
+func parseCSVHeader(header string) (string, string, map[string]string, error) {
+	metricsType := "gauge"
+	selector := strings.TrimSpace(header)
+	if i := strings.LastIndexAny(selector, " \t"); i >= 0 {
+		switch t := selector[i+1:]; t {
+		case "counter", "gauge":
+			metricsType = t
+			selector = strings.TrimSpace(selector[:i])
+		}
+	}
+
+	lbls, err := parser.ParseMetric(selector)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid CSV header %q: %w", header, err)
+	}
+	labels := lbls.Map()
+	name := labels[model.MetricNameLabel]
+	if name == "" {
+		return "", "", nil, fmt.Errorf("metric name not found in CSV header %q", header)
+	}
+	delete(labels, model.MetricNameLabel)
+	return name, metricsType, labels, nil
+}
+
+// parseCSV reads the recipes from a CSV stream.
+// Each column except the timestamp column is a series whose header is
+// a series selector optionally followed by the type, and each row is a step.
+// The values are the same as those in a sequence of a YAML recipe,
+// and an empty cell is a missing sample.
+func parseCSV(r io.Reader) ([]metricsRecipe, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+	// Allow the quotes of the label values in a header such as name{key="value"} without escaping.
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("CSV header not found")
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	recipes := make(map[string]*metricsRecipe)
+	columns := make([]*csvColumn, len(header))
+	timestampColumn := -1
+	for i, h := range header {
+		if strings.TrimSpace(h) == csvTimestampColumn {
+			if timestampColumn >= 0 {
+				return nil, errors.New("duplicated timestamp column")
+			}
+			timestampColumn = i
+			continue
+		}
+
+		name, metricsType, labels, err := parseCSVHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		recipe, ok := recipes[name]
+		if !ok {
+			recipe = &metricsRecipe{
+				Spec: spec{
+					Name: name,
+					Type: metricsType,
+				},
+			}
+			recipes[name] = recipe
+			names = append(names, name)
+		} else if recipe.Spec.Type != metricsType {
+			return nil, fmt.Errorf("%s: inconsistent types %s and %s", name, recipe.Spec.Type, metricsType)
+		}
+
+		data := metricsData{}
+		for key, value := range labels {
+			data.Labels = append(data.Labels, label{Key: key, Value: value})
+		}
+		recipe.Data = append(recipe.Data, data)
+		columns[i] = &csvColumn{
+			recipe: recipe,
+			data:   len(recipe.Data) - 1,
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no series column is found")
+	}
+
+	var timestamps []string
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows++
+		line, _ := reader.FieldPos(0)
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if i == timestampColumn {
+				timestamps = append(timestamps, cell)
+				continue
+			}
+
+			c := columns[i]
+			if c.sequence.Len() != 0 {
+				c.sequence.WriteByte(' ')
+			}
+			if cell == "" {
+				c.sequence.WriteByte('_')
+				continue
+			}
+			if _, err := strconv.ParseFloat(cell, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid value %q", line, cell)
+			}
+			c.sequence.WriteString(cell)
+		}
+	}
+	if rows == 0 {
+		return nil, errors.New("no row is found")
+	}
+
+	for _, c := range columns {
+		if c == nil {
+			continue
+		}
+		c.recipe.Data[c.data].Sequence = c.sequence.String()
+		if timestampColumn >= 0 {
+			c.recipe.Data[c.data].Timestamps = timestamps
+		}
+	}
+
+	result := make([]metricsRecipe, 0, len(names))
+	for _, name := range names {
+		recipe := recipes[name]
+		fillCSVLabels(recipe)
+		result = append(result, *recipe)
+	}
+	return result, nil
+}
+
+// fillCSVLabels sets the spec labels to all of the label names found in the data,
+// and sets a label which only some of the series have to the empty value for the others.
+func fillCSVLabels(recipe *metricsRecipe) {
+	labelSet := make(map[string]bool)
+	for _, data := range recipe.Data {
+		for _, l := range data.Labels {
+			labelSet[l.Key] = true
+		}
+	}
+	for key := range labelSet {
+		recipe.Spec.Labels = append(recipe.Spec.Labels, key)
+	}
+	sort.Strings(recipe.Spec.Labels)
+
+	for i, data := range recipe.Data {
+		values := make(map[string]string)
+		for _, l := range data.Labels {
+			values[l.Key] = l.Value
+		}
+		recipe.Data[i].Labels = nil
+		for _, key := range recipe.Spec.Labels {
+			recipe.Data[i].Labels = append(recipe.Data[i].Labels, label{Key: key, Value: values[key]})
+		}
+	}
+}
+
+// RegisterCSV is the same as Register except that the recipes are read from a CSV stream.
+// The first row is the header, in which each column names a series such as
+// `name{key="value"} counter` (the type defaults to gauge), and each of the following rows is a step.
+// A column named "timestamp" holds the timestamp of each step.
+// The values are the same as those in a sequence, and an empty cell is a missing sample.
+func (e *Exporter) RegisterCSV(r io.Reader) error {
+	recipe, err := parseCSV(r)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.register(recipe)
+}
+
+// ConvertCSV converts the recipes in a CSV stream into YAML. See RegisterCSV for the CSV format.
+func ConvertCSV(r io.Reader) ([]byte, error) {
+	recipe, err := parseCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalRecipes(recipe)
+}
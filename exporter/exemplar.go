@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+type exemplar struct {
+	Labels []label `yaml:"labels" json:"labels"`
+	// For histogram. The observed value to which the exemplar is attached.
+	// If omitted, the exemplar is attached to the last observed value in the step.
+	Value *float64 `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+type parsedExemplar struct {
+	labels prometheus.Labels
+	value  *float64
+}
+
+// parseExemplars validates the exemplars of each step.
+// A step without exemplar labels has no exemplar, which is represented by nil.
+func parseExemplars(exemplars []exemplar, steps int) ([]*parsedExemplar, error) {
+	if len(exemplars) > steps {
+		return nil, fmt.Errorf("the number of exemplars %d exceeds that of steps %d", len(exemplars), steps)
+	}
+
+	result := make([]*parsedExemplar, 0, len(exemplars))
+	for _, ex := range exemplars {
+		if len(ex.Labels) == 0 {
+			if ex.Value != nil {
+				return nil, fmt.Errorf("exemplar value %v is specified without labels", *ex.Value)
+			}
+			result = append(result, nil)
+			continue
+		}
+
+		labels := make(prometheus.Labels)
+		runes := 0
+		for _, l := range ex.Labels {
+			if !model.LabelName(l.Key).IsValid() {
+				return nil, fmt.Errorf("invalid exemplar label name: %s", l.Key)
+			}
+			if _, ok := labels[l.Key]; ok {
+				return nil, fmt.Errorf("duplicated exemplar label name: %s", l.Key)
+			}
+			labels[l.Key] = l.Value
+			runes += utf8.RuneCountInString(l.Key) + utf8.RuneCountInString(l.Value)
+		}
+		if runes > prometheus.ExemplarMaxRunes {
+			return nil, fmt.Errorf("exemplar labels %v exceed %d runes", labels, prometheus.ExemplarMaxRunes)
+		}
+		result = append(result, &parsedExemplar{
+			labels: labels,
+			value:  ex.Value,
+		})
+	}
+	return result, nil
+}
+
+// nextExemplar returns the exemplar of the current step and consumes it.
+func (pmd *parsedMetricsData) nextExemplar() *parsedExemplar {
+	if len(pmd.exemplars) == 0 {
+		return nil
+	}
+	ex := pmd.exemplars[0]
+	pmd.exemplars = pmd.exemplars[1:]
+	return ex
+}
+
+// exemplarIndex returns the index of the observed value to which the exemplar is attached.
+func exemplarIndex(ex *parsedExemplar, observedValues []float64) int {
+	if ex.value == nil {
+		return len(observedValues) - 1
+	}
+	for i, v := range observedValues {
+		if v == *ex.value {
+			return i
+		}
+	}
+	return -1
+}
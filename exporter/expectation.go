@@ -0,0 +1,32 @@
+package exporter
+
+// Expectation is a PromQL expression evaluated at a step of the recipes along with the expected result.
+// It is used by the test command and ignored by the exporter.
+type Expectation struct {
+	Expr string `yaml:"expr" json:"expr"`
+	// Step is the 1-origin index of the step, i.e. the number of scrapes, at which Expr is evaluated.
+	Step int `yaml:"step" json:"step"`
+	// Samples is the expected result. An empty list means the result is expected to be empty.
+	Samples []ExpectedSample `yaml:"samples" json:"samples"`
+}
+
+// ExpectedSample is a sample in the expected result of an expression.
+type ExpectedSample struct {
+	// Labels is the series selector such as `name{key="value"}`. It is empty for a scalar.
+	Labels string  `yaml:"labels" json:"labels"`
+	Value  float64 `yaml:"value" json:"value"`
+}
+
+// ParseExpectations returns the expectations defined in the recipes.
+func ParseExpectations(yamlData []byte) ([]Expectation, error) {
+	var recipe []metricsRecipe
+	if err := unmarshalAllRecipe(yamlData, &recipe); err != nil {
+		return nil, err
+	}
+
+	result := make([]Expectation, 0)
+	for _, r := range recipe {
+		result = append(result, r.Expectations...)
+	}
+	return result, nil
+}
@@ -2,17 +2,19 @@ package exporter
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"gopkg.in/yaml.v2"
 )
 
@@ -24,35 +26,49 @@ const (
 	Histogram
 )
 
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
+)
+
 var (
 	strToMetricsType map[string]metricsType
 
 	ConflictErr = errors.New("metrics conflict")
+	NotFoundErr = errors.New("metrics not found")
 )
 
 type metricsRecipe struct {
-	Spec spec          `yaml:"spec"`
-	Data []metricsData `yaml:"data"`
+	Spec spec          `yaml:"spec" json:"spec"`
+	Data []metricsData `yaml:"data" json:"data"`
+	// For the test command.
+	Expectations []Expectation `yaml:"expectations,omitempty" json:"expectations,omitempty"`
 }
 
 type spec struct {
-	Name    string    `yaml:"name"`
-	Type    string    `yaml:"type"`
-	Labels  []string  `yaml:"labels"`
-	Buckets []float64 `yaml:"buckets"`
+	Name    string    `yaml:"name" json:"name"`
+	Type    string    `yaml:"type" json:"type"`
+	Labels  []string  `yaml:"labels" json:"labels"`
+	Buckets []float64 `yaml:"buckets,omitempty" json:"buckets,omitempty"`
+	// For counter and histogram. The aggregation temporality used by the OTLP export.
+	Temporality string `yaml:"temporality,omitempty" json:"temporality,omitempty"`
 }
 
 type metricsData struct {
-	Labels []label `yaml:"labels"`
+	Labels []label `yaml:"labels" json:"labels"`
 	// For counter and gauge
-	Sequence string `yaml:"sequence"`
+	Sequence string `yaml:"sequence,omitempty" json:"sequence,omitempty"`
 	// For histogram
-	ObservedValues []string `yaml:"observedValues"`
+	ObservedValues []string `yaml:"observedValues,omitempty" json:"observedValues,omitempty"`
+	// For counter and histogram. The exemplar of each step.
+	Exemplars []exemplar `yaml:"exemplars,omitempty" json:"exemplars,omitempty"`
+	// For counter and gauge. The timestamp of each step.
+	Timestamps []string `yaml:"timestamps,omitempty" json:"timestamps,omitempty"`
 }
 
 type label struct {
-	Key   string `yaml:"key"`
-	Value string `yaml:"value"`
+	Key   string `yaml:"key" json:"key"`
+	Value string `yaml:"value" json:"value"`
 }
 
 type parsedMetricsData struct {
@@ -61,21 +77,57 @@ type parsedMetricsData struct {
 	sequence []float64
 	// For histogram
 	observedValues [][]float64
+	exemplars      []*parsedExemplar
+	timestamps     []*parsedTimestamp
+	// For counter. The sum of the added values, and whether the series is
+	// hidden by a missing sample.
+	total  float64
+	hidden bool
+}
+
+type metricExporter interface {
+	update(metName string, now time.Time)
+	isExhausted() bool
+	collector() prometheus.Collector
+	// reset brings the exporter back to the state right after it was built from the recipe,
+	// without re-registering its collector.
+	reset(recipe *metricsRecipe) error
+	// appendData appends the values to the data with the same labels, or adds the data.
+	appendData(pmds []*parsedMetricsData)
 }
 
 type counterExporter struct {
-	counterVec        *prometheus.CounterVec
-	parsedMetricsData []*parsedMetricsData
+	counterVec         *prometheus.CounterVec
+	timestampCollector *timestampCollector
+	parsedMetricsData  []*parsedMetricsData
 }
 
-func newCounterExporter(recipe *metricsRecipe) (*counterExporter, error) {
-	counterVec := promauto.NewCounterVec(
+func newCounterExporter(recipe *metricsRecipe, registerer prometheus.Registerer) (*counterExporter, error) {
+	counterVec := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: recipe.Spec.Name,
 		},
 		recipe.Spec.Labels,
 	)
 
+	pmds, err := parseCounterData(recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := newTimestampCollector(counterVec)
+	if err := registerer.Register(tc); err != nil {
+		return nil, err
+	}
+
+	return &counterExporter{
+		counterVec:         counterVec,
+		timestampCollector: tc,
+		parsedMetricsData:  pmds,
+	}, nil
+}
+
+func parseCounterData(recipe *metricsRecipe) ([]*parsedMetricsData, error) {
 	var pmds []*parsedMetricsData
 	for _, metData := range recipe.Data {
 		parsedSeq, err := parseSequence(metData.Sequence)
@@ -95,47 +147,126 @@ func newCounterExporter(recipe *metricsRecipe) (*counterExporter, error) {
 			return nil, fmt.Errorf("data label is invalid: %v", labels)
 		}
 
+		exemplars, err := parseExemplars(metData.Exemplars, len(parsedSeq))
+		if err != nil {
+			return nil, err
+		}
+		for _, ex := range exemplars {
+			if ex != nil && ex.value != nil {
+				return nil, fmt.Errorf("exemplar value is not supported for counter")
+			}
+		}
+
+		timestamps, err := parseTimestamps(metData.Timestamps, len(parsedSeq))
+		if err != nil {
+			return nil, err
+		}
+
 		pmds = append(pmds, &parsedMetricsData{
-			labels:   labels,
-			sequence: parsedSeq,
+			labels:     labels,
+			sequence:   parsedSeq,
+			exemplars:  exemplars,
+			timestamps: timestamps,
 		})
 	}
-
-	return &counterExporter{
-		counterVec:        counterVec,
-		parsedMetricsData: pmds,
-	}, nil
+	return pmds, nil
 }
 
-func (ce *counterExporter) update(metName string) {
+func (ce *counterExporter) update(metName string, now time.Time) {
 	if len(ce.parsedMetricsData) == 0 {
 		return
 	}
-	toBeDeletedDataIndex := make([]int, 0)
-	for i, pmd := range ce.parsedMetricsData {
-		ce.counterVec.With(pmd.labels).Add(pmd.sequence[0])
+	for _, pmd := range ce.parsedMetricsData {
+		// The exhausted data is kept so that more values can be appended to it.
+		if len(pmd.sequence) == 0 {
+			continue
+		}
+		ce.timestampCollector.set(pmd.labels, pmd.nextTimestamp(), now)
+		ex := pmd.nextExemplar()
+		if v := pmd.sequence[0]; isMissing(v) {
+			ce.counterVec.Delete(pmd.labels)
+			pmd.hidden = true
+		} else {
+			counter := ce.counterVec.With(pmd.labels)
+			if pmd.hidden {
+				// The counter is recreated from zero, so carry over the value before the missing samples.
+				counter.Add(pmd.total)
+				pmd.hidden = false
+			}
+			if ex != nil {
+				counter.(prometheus.ExemplarAdder).AddWithExemplar(v, ex.labels)
+			} else {
+				counter.Add(v)
+			}
+			pmd.total += v
+		}
 		pmd.sequence = pmd.sequence[1:]
 		if len(pmd.sequence) == 0 {
 			log.Printf("empty value found for %s.", metName)
-			toBeDeletedDataIndex = append(toBeDeletedDataIndex, i)
 		}
 	}
-	ce.parsedMetricsData = deleteEntriesFromParsedMetricsData(toBeDeletedDataIndex, ce.parsedMetricsData)
+}
+
+func (ce *counterExporter) isExhausted() bool {
+	for _, pmd := range ce.parsedMetricsData {
+		if len(pmd.sequence) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (ce *counterExporter) collector() prometheus.Collector {
+	return ce.timestampCollector
+}
+
+func (ce *counterExporter) reset(recipe *metricsRecipe) error {
+	pmds, err := parseCounterData(recipe)
+	if err != nil {
+		return err
+	}
+	ce.counterVec.Reset()
+	ce.timestampCollector.reset()
+	ce.parsedMetricsData = pmds
+	return nil
+}
+
+func (ce *counterExporter) appendData(pmds []*parsedMetricsData) {
+	ce.parsedMetricsData = appendParsedMetricsData(ce.parsedMetricsData, pmds)
 }
 
 type gaugeExporter struct {
-	gaugeVec          *prometheus.GaugeVec
-	parsedMetricsData []*parsedMetricsData
+	gaugeVec           *prometheus.GaugeVec
+	timestampCollector *timestampCollector
+	parsedMetricsData  []*parsedMetricsData
 }
 
-func newGaugeExporter(recipe *metricsRecipe) (*gaugeExporter, error) {
-	gaugeVec := promauto.NewGaugeVec(
+func newGaugeExporter(recipe *metricsRecipe, registerer prometheus.Registerer) (*gaugeExporter, error) {
+	gaugeVec := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: recipe.Spec.Name,
 		},
 		recipe.Spec.Labels,
 	)
 
+	pmds, err := parseGaugeData(recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := newTimestampCollector(gaugeVec)
+	if err := registerer.Register(tc); err != nil {
+		return nil, err
+	}
+
+	return &gaugeExporter{
+		gaugeVec:           gaugeVec,
+		timestampCollector: tc,
+		parsedMetricsData:  pmds,
+	}, nil
+}
+
+func parseGaugeData(recipe *metricsRecipe) ([]*parsedMetricsData, error) {
 	var pmds []*parsedMetricsData
 	for _, metData := range recipe.Data {
 		parsedSeq, err := parseSequence(metData.Sequence)
@@ -151,32 +282,71 @@ func newGaugeExporter(recipe *metricsRecipe) (*gaugeExporter, error) {
 			return nil, fmt.Errorf("data label is invalid: %v", labels)
 		}
 
+		if len(metData.Exemplars) != 0 {
+			return nil, fmt.Errorf("exemplars are not supported for gauge")
+		}
+
+		timestamps, err := parseTimestamps(metData.Timestamps, len(parsedSeq))
+		if err != nil {
+			return nil, err
+		}
+
 		pmds = append(pmds, &parsedMetricsData{
-			labels:   labels,
-			sequence: parsedSeq,
+			labels:     labels,
+			sequence:   parsedSeq,
+			timestamps: timestamps,
 		})
 	}
-
-	return &gaugeExporter{
-		gaugeVec:          gaugeVec,
-		parsedMetricsData: pmds,
-	}, nil
+	return pmds, nil
 }
 
-func (ga *gaugeExporter) update(metName string) {
+func (ga *gaugeExporter) update(metName string, now time.Time) {
 	if len(ga.parsedMetricsData) == 0 {
 		return
 	}
-	toBeDeletedDataIndex := make([]int, 0)
-	for i, pmd := range ga.parsedMetricsData {
-		ga.gaugeVec.With(pmd.labels).Set(pmd.sequence[0])
+	for _, pmd := range ga.parsedMetricsData {
+		if len(pmd.sequence) == 0 {
+			continue
+		}
+		ga.timestampCollector.set(pmd.labels, pmd.nextTimestamp(), now)
+		if v := pmd.sequence[0]; isMissing(v) {
+			ga.gaugeVec.Delete(pmd.labels)
+		} else {
+			ga.gaugeVec.With(pmd.labels).Set(v)
+		}
 		pmd.sequence = pmd.sequence[1:]
 		if len(pmd.sequence) == 0 {
 			log.Printf("empty value found for %s.", metName)
-			toBeDeletedDataIndex = append(toBeDeletedDataIndex, i)
 		}
 	}
-	ga.parsedMetricsData = deleteEntriesFromParsedMetricsData(toBeDeletedDataIndex, ga.parsedMetricsData)
+}
+
+func (ga *gaugeExporter) isExhausted() bool {
+	for _, pmd := range ga.parsedMetricsData {
+		if len(pmd.sequence) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (ga *gaugeExporter) collector() prometheus.Collector {
+	return ga.timestampCollector
+}
+
+func (ga *gaugeExporter) reset(recipe *metricsRecipe) error {
+	pmds, err := parseGaugeData(recipe)
+	if err != nil {
+		return err
+	}
+	ga.gaugeVec.Reset()
+	ga.timestampCollector.reset()
+	ga.parsedMetricsData = pmds
+	return nil
+}
+
+func (ga *gaugeExporter) appendData(pmds []*parsedMetricsData) {
+	ga.parsedMetricsData = appendParsedMetricsData(ga.parsedMetricsData, pmds)
 }
 
 type histogramExporter struct {
@@ -184,8 +354,8 @@ type histogramExporter struct {
 	parsedMetricsData []*parsedMetricsData
 }
 
-func newHistogramExporter(recipe *metricsRecipe) (*histogramExporter, error) {
-	histogramVec := promauto.NewHistogramVec(
+func newHistogramExporter(recipe *metricsRecipe, registerer prometheus.Registerer) (*histogramExporter, error) {
+	histogramVec := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    recipe.Spec.Name,
 			Buckets: recipe.Spec.Buckets,
@@ -193,6 +363,22 @@ func newHistogramExporter(recipe *metricsRecipe) (*histogramExporter, error) {
 		recipe.Spec.Labels,
 	)
 
+	pmds, err := parseHistogramData(recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := registerer.Register(histogramVec); err != nil {
+		return nil, err
+	}
+
+	return &histogramExporter{
+		histogramVec:      histogramVec,
+		parsedMetricsData: pmds,
+	}, nil
+}
+
+func parseHistogramData(recipe *metricsRecipe) ([]*parsedMetricsData, error) {
 	var pmds []*parsedMetricsData
 	for _, metData := range recipe.Data {
 		parsedValues, err := parseObservedValues(metData.ObservedValues)
@@ -208,55 +394,128 @@ func newHistogramExporter(recipe *metricsRecipe) (*histogramExporter, error) {
 			return nil, fmt.Errorf("data label is invalid: %v", labels)
 		}
 
+		if len(metData.Timestamps) != 0 {
+			return nil, fmt.Errorf("timestamps are not supported for histogram")
+		}
+
+		exemplars, err := parseExemplars(metData.Exemplars, len(parsedValues))
+		if err != nil {
+			return nil, err
+		}
+		for i, ex := range exemplars {
+			if ex != nil && exemplarIndex(ex, parsedValues[i]) < 0 {
+				return nil, fmt.Errorf("exemplar value %v is not observed in the step %d", *ex.value, i+1)
+			}
+		}
+
 		pmds = append(pmds, &parsedMetricsData{
 			labels:         labels,
 			observedValues: parsedValues,
+			exemplars:      exemplars,
 		})
 	}
-
-	return &histogramExporter{
-		histogramVec:      histogramVec,
-		parsedMetricsData: pmds,
-	}, nil
+	return pmds, nil
 }
 
-func (hi *histogramExporter) update(metName string) {
+func (hi *histogramExporter) update(metName string, now time.Time) {
 	if len(hi.parsedMetricsData) == 0 {
 		return
 	}
-	toBeDeletedDataIndex := make([]int, 0)
-	for i, pmd := range hi.parsedMetricsData {
-		for _, v := range pmd.observedValues[0] {
-			hi.histogramVec.With(pmd.labels).Observe(v)
+	for _, pmd := range hi.parsedMetricsData {
+		if len(pmd.observedValues) == 0 {
+			continue
+		}
+		exIndex := -1
+		ex := pmd.nextExemplar()
+		if ex != nil {
+			exIndex = exemplarIndex(ex, pmd.observedValues[0])
+		}
+		for j, v := range pmd.observedValues[0] {
+			if j == exIndex {
+				hi.histogramVec.With(pmd.labels).(prometheus.ExemplarObserver).ObserveWithExemplar(v, ex.labels)
+			} else {
+				hi.histogramVec.With(pmd.labels).Observe(v)
+			}
 		}
 		pmd.observedValues = pmd.observedValues[1:]
 		if len(pmd.observedValues) == 0 {
 			log.Printf("empty value found for %s.", metName)
-			toBeDeletedDataIndex = append(toBeDeletedDataIndex, i)
 		}
 	}
-	hi.parsedMetricsData = deleteEntriesFromParsedMetricsData(toBeDeletedDataIndex, hi.parsedMetricsData)
 }
 
-var types map[string]metricsType
+func (hi *histogramExporter) isExhausted() bool {
+	for _, pmd := range hi.parsedMetricsData {
+		if len(pmd.observedValues) != 0 {
+			return false
+		}
+	}
+	return true
+}
 
-var counterExporters map[string]*counterExporter
-var gaugeExporters map[string]*gaugeExporter
-var histogramExporters map[string]*histogramExporter
+func (hi *histogramExporter) collector() prometheus.Collector {
+	return hi.histogramVec
+}
 
-var mu sync.Mutex
+func (hi *histogramExporter) reset(recipe *metricsRecipe) error {
+	pmds, err := parseHistogramData(recipe)
+	if err != nil {
+		return err
+	}
+	hi.histogramVec.Reset()
+	hi.parsedMetricsData = pmds
+	return nil
+}
+
+func (hi *histogramExporter) appendData(pmds []*parsedMetricsData) {
+	hi.parsedMetricsData = appendParsedMetricsData(hi.parsedMetricsData, pmds)
+}
+
+// registeredMetrics is a metrics exporter along with the recipe it was built from
+// and the number of updates applied to it, from which the exporter can be rebuilt.
+type registeredMetrics struct {
+	metricExporter
+	recipe metricsRecipe
+	// initial is the recipe as it was registered if data has been appended to it since then.
+	// Reset brings the metrics back to this recipe.
+	initial *metricsRecipe
+	steps   int
+	// paused stops Update from advancing the metrics.
+	paused bool
+}
+
+// Exporter holds a set of registered metrics and exports them
+// through its own registerer.
+type Exporter struct {
+	registerer prometheus.Registerer
+	exporters  map[string]*registeredMetrics
+	// generation is incremented every time the state of the exporter changes.
+	generation uint64
+	// paused stops Update from advancing any metrics.
+	paused bool
+	mu     sync.Mutex
+}
 
 func init() {
 	strToMetricsType = make(map[string]metricsType)
 	strToMetricsType["counter"] = Counter
 	strToMetricsType["gauge"] = Gauge
 	strToMetricsType["histogram"] = Histogram
+}
 
-	types = make(map[string]metricsType)
+func New(registerer prometheus.Registerer) *Exporter {
+	return &Exporter{
+		registerer: registerer,
+		exporters:  make(map[string]*registeredMetrics),
+	}
+}
 
-	counterExporters = make(map[string]*counterExporter)
-	gaugeExporters = make(map[string]*gaugeExporter)
-	histogramExporters = make(map[string]*histogramExporter)
+// missingValue marks a missing sample in a parsed sequence.
+// It is a NaN with its own bit pattern so that it is distinguished from the NaN values in a sequence.
+var missingValue = math.Float64frombits(0x7ff0000000000003)
+
+func isMissing(v float64) bool {
+	return math.Float64bits(v) == math.Float64bits(missingValue)
 }
 
 func parseSequence(sequence string) ([]float64, error) {
@@ -264,7 +523,18 @@ func parseSequence(sequence string) ([]float64, error) {
 
 	tokens := strings.Split(sequence, " ")
 	for _, token := range tokens {
-		if strings.Contains(token, "x") {
+		if token == "_" {
+			result = append(result, missingValue)
+		} else if strings.HasPrefix(token, "_x") {
+			// _x3 style (3 missing samples)
+			times, err := strconv.Atoi(strings.TrimPrefix(token, "_x"))
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < times; i++ {
+				result = append(result, missingValue)
+			}
+		} else if strings.Contains(token, "x") {
 			initStr := ""
 			stepStr := ""
 			timesStr := ""
@@ -337,10 +607,20 @@ func parseObservedValues(values []string) ([][]float64, error) {
 	result := make([][]float64, 0)
 
 	for _, seq := range values {
+		// An empty item means no observation in the step.
+		if seq == "" {
+			result = append(result, []float64{})
+			continue
+		}
 		parsedSeq, err := parseSequence(seq)
 		if err != nil {
 			return nil, err
 		}
+		for _, v := range parsedSeq {
+			if isMissing(v) {
+				return nil, fmt.Errorf("missing sample is not supported in observed values: %s", seq)
+			}
+		}
 		result = append(result, parsedSeq)
 	}
 
@@ -363,15 +643,46 @@ func unmarshalAllRecipe(in []byte, out *[]metricsRecipe) error {
 	return nil
 }
 
-func conflict(recipe []metricsRecipe) (bool, int) {
-	for i, r := range recipe {
-		if _, ok := counterExporters[r.Spec.Name]; ok {
-			return true, i
+func unmarshalJSONRecipe(in []byte, out *[]metricsRecipe) error {
+	trimmed := bytes.TrimSpace(in)
+	if len(trimmed) != 0 && trimmed[0] == '[' {
+		return json.Unmarshal(trimmed, out)
+	}
+
+	var mr metricsRecipe
+	if err := json.Unmarshal(trimmed, &mr); err != nil {
+		return err
+	}
+	*out = append(*out, mr)
+	return nil
+}
+
+// SplitRecipes splits a multi-document recipe into the individual recipes
+// keyed by the metrics name.
+func SplitRecipes(yamlData []byte) (map[string][]byte, error) {
+	var recipe []metricsRecipe
+	err := unmarshalAllRecipe(yamlData, &recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte)
+	for _, r := range recipe {
+		if _, ok := result[r.Spec.Name]; ok {
+			return nil, fmt.Errorf("%s: %w", r.Spec.Name, ConflictErr)
 		}
-		if _, ok := gaugeExporters[r.Spec.Name]; ok {
-			return true, i
+		doc, err := yaml.Marshal(&r)
+		if err != nil {
+			return nil, err
 		}
-		if _, ok := histogramExporters[r.Spec.Name]; ok {
+		result[r.Spec.Name] = doc
+	}
+	return result, nil
+}
+
+func (e *Exporter) conflict(recipe []metricsRecipe) (bool, int) {
+	for i, r := range recipe {
+		if _, ok := e.exporters[r.Spec.Name]; ok {
 			return true, i
 		}
 	}
@@ -408,6 +719,15 @@ func validSpec(recipe []metricsRecipe) (bool, int) {
 				return false, i
 			}
 		}
+		switch r.Spec.Temporality {
+		case "", TemporalityCumulative:
+		case TemporalityDelta:
+			if strToMetricsType[r.Spec.Type] == Gauge {
+				return false, i
+			}
+		default:
+			return false, i
+		}
 	}
 	return true, -1
 }
@@ -433,9 +753,10 @@ func allPositive(sequence []float64) bool {
 	}
 	return true
 }
-func Register(yamlData []byte) error {
-	mu.Lock()
-	defer mu.Unlock()
+
+func (e *Exporter) Register(yamlData []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	var recipe []metricsRecipe
 	err := unmarshalAllRecipe(yamlData, &recipe)
@@ -443,148 +764,229 @@ func Register(yamlData []byte) error {
 		return err
 	}
 
-	if result, i := conflict(recipe); result {
+	return e.register(recipe)
+}
+
+// RegisterJSON is the same as Register except that the recipes are written in JSON.
+// jsonData is either a single recipe object or an array of them.
+func (e *Exporter) RegisterJSON(jsonData []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var recipe []metricsRecipe
+	err := unmarshalJSONRecipe(jsonData, &recipe)
+	if err != nil {
+		return err
+	}
+
+	return e.register(recipe)
+}
+
+// Lock should be acquired by the caller.
+func (e *Exporter) register(recipe []metricsRecipe) error {
+	if result, i := e.conflict(recipe); result {
 		return fmt.Errorf("%s: %w", recipe[i].Spec.Name, ConflictErr)
 	}
 
 	if result, i := validSpec(recipe); !result {
-		return fmt.Errorf("invalid metrics spec. name: %s, type: %s, labels: %v, buckets: %v",
-			recipe[i].Spec.Name, recipe[i].Spec.Type, recipe[i].Spec.Labels, recipe[i].Spec.Buckets)
+		return fmt.Errorf("invalid metrics spec. name: %s, type: %s, labels: %v, buckets: %v, temporality: %s",
+			recipe[i].Spec.Name, recipe[i].Spec.Type, recipe[i].Spec.Labels, recipe[i].Spec.Buckets, recipe[i].Spec.Temporality)
 	}
 
 	for _, r := range recipe {
-		switch strToMetricsType[r.Spec.Type] {
-		case Counter:
-			exporter, err := newCounterExporter(&r)
-			if err != nil {
-				return err
-			}
-			counterExporters[r.Spec.Name] = exporter
-		case Gauge:
-			exporter, err := newGaugeExporter(&r)
-			if err != nil {
-				return err
-			}
-			gaugeExporters[r.Spec.Name] = exporter
-		case Histogram:
-			exporter, err := newHistogramExporter(&r)
-			if err != nil {
-				return err
-			}
-			histogramExporters[r.Spec.Name] = exporter
-		default:
-			panic(fmt.Sprintf("unknown type: %d", types[r.Spec.Name]))
+		exporter, err := newMetricExporter(&r, e.registerer)
+		if err != nil {
+			return err
 		}
-		types[r.Spec.Name] = strToMetricsType[r.Spec.Type]
+		e.exporters[r.Spec.Name] = &registeredMetrics{
+			metricExporter: exporter,
+			recipe:         r,
+		}
+		e.generation++
 	}
 
 	return nil
 }
 
-func deleteEntriesFromParsedMetricsData(toBeDeletedDataIndex []int, parsedMetricsData []*parsedMetricsData) []*parsedMetricsData {
-	sort.Slice(toBeDeletedDataIndex, func(i, j int) bool {
-		return toBeDeletedDataIndex[i] > toBeDeletedDataIndex[j]
-	})
-	for _, delIndex := range toBeDeletedDataIndex {
-		parsedMetricsData[delIndex] = parsedMetricsData[len(parsedMetricsData)-1]
-		parsedMetricsData = parsedMetricsData[:len(parsedMetricsData)-1]
+func newMetricExporter(recipe *metricsRecipe, registerer prometheus.Registerer) (metricExporter, error) {
+	switch strToMetricsType[recipe.Spec.Type] {
+	case Counter:
+		return newCounterExporter(recipe, registerer)
+	case Gauge:
+		return newGaugeExporter(recipe, registerer)
+	case Histogram:
+		return newHistogramExporter(recipe, registerer)
+	default:
+		panic(fmt.Sprintf("unknown type: %s", recipe.Spec.Type))
 	}
-	return parsedMetricsData
 }
 
-func Update() {
-	mu.Lock()
-	defer mu.Unlock()
+func (e *Exporter) Update() {
+	e.UpdateAt(time.Now())
+}
 
-	for metName, exporter := range counterExporters {
-		exporter.update(metName)
-	}
+// UpdateAt is the same as Update except that the relative timestamps
+// in the recipes are resolved against now instead of the current time.
+func (e *Exporter) UpdateAt(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	for metName, exporter := range gaugeExporters {
-		exporter.update(metName)
+	if e.paused {
+		return
 	}
-
-	for metName, exporter := range histogramExporters {
-		exporter.update(metName)
+	for metName, exporter := range e.exporters {
+		if exporter.paused {
+			continue
+		}
+		exporter.update(metName, now)
+		exporter.steps++
+		e.generation++
 	}
 }
 
-func Clear(force bool) {
-	mu.Lock()
-	defer mu.Unlock()
+func (e *Exporter) Clear(force bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	log.Printf("param: force=%v", force)
 
 	toBeDeletedMetrics := make([]string, 0)
-	for metName, exporter := range counterExporters {
-		shouldBeRemoved := true
-		if !force {
-			for _, pmd := range exporter.parsedMetricsData {
-				if len(pmd.sequence) != 0 {
-					shouldBeRemoved = false
-				}
-			}
-		}
-		if shouldBeRemoved {
+	for metName, exporter := range e.exporters {
+		if force || exporter.isExhausted() {
 			toBeDeletedMetrics = append(toBeDeletedMetrics, metName)
 		}
 	}
 
-	for metName, exporter := range gaugeExporters {
-		shouldBeRemoved := true
-		if !force {
-			for _, pmd := range exporter.parsedMetricsData {
-				if len(pmd.sequence) != 0 {
-					shouldBeRemoved = false
-				}
-			}
-		}
-		if shouldBeRemoved {
-			toBeDeletedMetrics = append(toBeDeletedMetrics, metName)
+	for _, metName := range toBeDeletedMetrics {
+		e.clearSpecifiedMetrics(metName)
+	}
+}
+
+// Delete removes the specified metrics regardless of their remaining data,
+// and returns the number of the removed metrics. Unknown metrics names are ignored.
+func (e *Exporter) Delete(metricsNames ...string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	deleted := 0
+	for _, metName := range metricsNames {
+		if _, ok := e.exporters[metName]; ok {
+			e.clearSpecifiedMetrics(metName)
+			deleted++
 		}
 	}
+	return deleted
+}
 
-	for metName, exporter := range histogramExporters {
-		shouldBeRemoved := true
-		if !force {
-			for _, pmd := range exporter.parsedMetricsData {
-				if len(pmd.observedValues) != 0 {
-					shouldBeRemoved = false
-				}
-			}
+// Exhausted reports whether all of the registered metrics have no data to export anymore.
+// It returns false if no metrics is registered.
+func (e *Exporter) Exhausted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.exporters) == 0 {
+		return false
+	}
+	for _, exporter := range e.exporters {
+		if !exporter.isExhausted() {
+			return false
 		}
-		if shouldBeRemoved {
-			toBeDeletedMetrics = append(toBeDeletedMetrics, metName)
+	}
+	return true
+}
+
+// Registered reports whether the metrics is registered.
+func (e *Exporter) Registered(metricsName string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, ok := e.exporters[metricsName]
+	return ok
+}
+
+// Temporality returns the aggregation temporality of the metrics.
+// It returns TemporalityCumulative for unknown metrics.
+func (e *Exporter) Temporality(metricsName string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if exporter, ok := e.exporters[metricsName]; ok && exporter.recipe.Spec.Temporality == TemporalityDelta {
+		return TemporalityDelta
+	}
+	return TemporalityCumulative
+}
+
+// Recipes returns the registered recipes sorted by the metrics name.
+func (e *Exporter) Recipes() ([][]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make([]string, 0, len(e.exporters))
+	for metName := range e.exporters {
+		names = append(names, metName)
+	}
+	sort.Strings(names)
+
+	recipes := make([][]byte, 0, len(names))
+	for _, metName := range names {
+		doc, err := yaml.Marshal(&e.exporters[metName].recipe)
+		if err != nil {
+			return nil, err
 		}
+		recipes = append(recipes, doc)
 	}
+	return recipes, nil
+}
 
-	for _, metName := range toBeDeletedMetrics {
-		clearSpecifiedMetrics(metName)
+// MetricsStatus is the state of registered metrics.
+type MetricsStatus struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Series int    `json:"series"`
+	// Steps is the number of updates applied since the metrics was registered.
+	Steps     int  `json:"steps"`
+	Exhausted bool `json:"exhausted"`
+	// Paused is true if the metrics is paused by itself. See also Exporter.Paused.
+	Paused bool `json:"paused"`
+}
+
+// Status returns the state of the registered metrics sorted by the metrics name.
+func (e *Exporter) Status() []MetricsStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := make([]MetricsStatus, 0, len(e.exporters))
+	for metName, exporter := range e.exporters {
+		status = append(status, MetricsStatus{
+			Name:      metName,
+			Type:      exporter.recipe.Spec.Type,
+			Series:    len(exporter.recipe.Data),
+			Steps:     exporter.steps,
+			Exhausted: exporter.isExhausted(),
+			Paused:    exporter.paused,
+		})
 	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
+
+// Generation returns a number which changes every time the state of the exporter changes.
+func (e *Exporter) Generation() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.generation
 }
 
 // Lock should be acquired by the caller.
-func clearSpecifiedMetrics(metricsName string) {
-	switch types[metricsName] {
-	case Counter:
-		if !prometheus.Unregister(counterExporters[metricsName].counterVec) {
-			log.Printf("unregister failed. metricsName = %s", metricsName)
-		}
-		delete(counterExporters, metricsName)
-	case Gauge:
-		if !prometheus.Unregister(gaugeExporters[metricsName].gaugeVec) {
-			log.Printf("unregister failed. metricsName = %s", metricsName)
-		}
-		delete(gaugeExporters, metricsName)
-	case Histogram:
-		if !prometheus.Unregister(histogramExporters[metricsName].histogramVec) {
-			log.Printf("unregister failed. metricsName = %s", metricsName)
-		}
-		delete(histogramExporters, metricsName)
-	default:
-		panic(fmt.Sprintf("unknown type: %d", types[metricsName]))
+func (e *Exporter) clearSpecifiedMetrics(metricsName string) {
+	if !e.registerer.Unregister(e.exporters[metricsName].collector()) {
+		log.Printf("unregister failed. metricsName = %s", metricsName)
 	}
-	delete(types, metricsName)
+	delete(e.exporters, metricsName)
+	e.generation++
 
 	log.Printf("metrics %v was removed", metricsName)
 }
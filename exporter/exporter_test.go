@@ -1,8 +1,17 @@
 package exporter
 
 import (
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -115,6 +124,60 @@ func TestParseSequence(t *testing.T) {
 	}
 }
 
+func TestParseSequenceWithMissingSamples(t *testing.T) {
+	parsedSeq, err := parseSequence("1 _ 2 _x2 3")
+	require.NoError(t, err)
+	require.Len(t, parsedSeq, 6)
+	missing := make([]bool, len(parsedSeq))
+	for i, v := range parsedSeq {
+		missing[i] = isMissing(v)
+	}
+	assert.Equal(t, []bool{false, true, false, true, true, false}, missing)
+	assert.Equal(t, 3.0, parsedSeq[5])
+
+	// a NaN value is not a missing sample
+	parsedSeq, err = parseSequence("NaN")
+	require.NoError(t, err)
+	assert.False(t, isMissing(parsedSeq[0]))
+
+	_, err = parseSequence("_x")
+	assert.Error(t, err)
+	_, err = parseObservedValues([]string{"1 _"})
+	assert.Error(t, err)
+}
+
+func TestMissingSamples(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	require.NoError(t, e.Register([]byte(strings.NewReplacer("1 2", "1 _ 2", "5 3 1", "_ 3 1").Replace(testRecipe))))
+
+	values := func() map[string]float64 {
+		t.Helper()
+		e.Update()
+		mfs, err := registry.Gather()
+		require.NoError(t, err)
+		result := make(map[string]float64)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				result[mf.GetName()] = m.GetCounter().GetValue() + m.GetGauge().GetValue()
+			}
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]float64{"test_counter": 1}, values())
+	assert.Equal(t, map[string]float64{"test_gauge": 3}, values())
+	// the counter carries on after the missing sample
+	assert.Equal(t, map[string]float64{"test_counter": 3, "test_gauge": 1}, values())
+}
+
+func TestParseObservedValues(t *testing.T) {
+	observed, err := parseObservedValues([]string{"1 2", "", "3x1"})
+	require.NoError(t, err)
+	// an empty item means no observation
+	assert.Equal(t, [][]float64{{1, 2}, {}, {3, 3}}, observed)
+}
+
 func TestInvalidDataLabel(t *testing.T) {
 	specLabel := []string{"aaa", "bbb"}
 
@@ -210,3 +273,784 @@ func TestValidBuckets(t *testing.T) {
 		})
 	}
 }
+
+const testRecipe = `spec:
+  name: test_counter
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2
+---
+spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 5 3 1
+`
+
+func TestExporter(t *testing.T) {
+	// Each exporter has its own registry, so they can run in parallel.
+	for _, desc := range []string{"instance1", "instance2"} {
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+
+			registry := prometheus.NewRegistry()
+			e := New(registry)
+			require.NoError(t, e.Register([]byte(testRecipe)))
+			assert.ErrorIs(t, e.Register([]byte(testRecipe)), ConflictErr)
+
+			e.Update()
+			e.Update()
+			assert.Equal(t, 3.0, testutil.ToFloat64(e.exporters["test_counter"].collector()))
+			assert.Equal(t, 3.0, testutil.ToFloat64(e.exporters["test_gauge"].collector()))
+
+			// Only the drained counter is removed.
+			e.Clear(false)
+			count, err := testutil.GatherAndCount(registry)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+
+			e.Clear(true)
+			count, err = testutil.GatherAndCount(registry)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		})
+	}
+}
+
+func TestStatusAndDelete(t *testing.T) {
+	e := New(prometheus.NewRegistry())
+	require.NoError(t, e.Register([]byte(testRecipe)))
+	e.Update()
+	e.Update()
+
+	assert.Equal(t, []MetricsStatus{
+		{Name: "test_counter", Type: "counter", Series: 1, Steps: 2, Exhausted: true},
+		{Name: "test_gauge", Type: "gauge", Series: 1, Steps: 2, Exhausted: false},
+	}, e.Status())
+
+	assert.Equal(t, 1, e.Delete("test_gauge", "unknown"))
+	assert.Equal(t, 0, e.Delete("test_gauge"))
+	assert.Len(t, e.Status(), 1)
+}
+
+func TestStepControl(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	require.NoError(t, e.Register([]byte(testRecipe)))
+	counter := func() float64 {
+		return testutil.ToFloat64(e.exporters["test_counter"].collector())
+	}
+	gauge := func() float64 {
+		return testutil.ToFloat64(e.exporters["test_gauge"].collector())
+	}
+
+	// A paused metrics is not advanced by Update.
+	require.NoError(t, e.Pause("test_gauge"))
+	e.Update()
+	assert.Equal(t, 1.0, counter())
+	assert.Equal(t, 0, e.exporters["test_gauge"].steps)
+
+	require.NoError(t, e.Pause())
+	assert.True(t, e.Paused())
+	e.Update()
+	assert.Equal(t, 1.0, counter())
+
+	// Step advances the metrics even if they are paused.
+	require.NoError(t, e.Step(2, "test_gauge"))
+	assert.Equal(t, 3.0, gauge())
+	require.NoError(t, e.Step(1))
+	assert.Equal(t, 3.0, counter())
+	assert.Equal(t, 1.0, gauge())
+
+	// Step stops at the end of the sequences.
+	require.NoError(t, e.Step(math.MaxInt))
+	assert.Equal(t, 2, e.exporters["test_counter"].steps)
+	assert.Equal(t, 3, e.exporters["test_gauge"].steps)
+	assert.Equal(t, 3.0, counter())
+
+	// Resume without a name resumes the metrics paused by name as well.
+	require.NoError(t, e.Resume())
+	assert.False(t, e.Paused())
+	assert.False(t, e.exporters["test_gauge"].paused)
+
+	// The counter goes back as well.
+	require.NoError(t, e.Pause("test_gauge"))
+	require.NoError(t, e.Rewind(1))
+	assert.Equal(t, 1.0, counter())
+	assert.Equal(t, 5.0, gauge())
+	assert.True(t, e.exporters["test_gauge"].paused)
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// The metrics at or before the step is not changed.
+	require.NoError(t, e.Rewind(2, "test_counter"))
+	assert.Equal(t, 1.0, counter())
+	require.NoError(t, e.Rewind(0, "test_counter"))
+	assert.Equal(t, 0, e.exporters["test_counter"].steps)
+	e.Update()
+	assert.Equal(t, 1.0, counter())
+	assert.Equal(t, 5.0, gauge())
+
+	assert.ErrorIs(t, e.Pause("unknown"), NotFoundErr)
+	assert.ErrorIs(t, e.Resume("unknown"), NotFoundErr)
+	assert.ErrorIs(t, e.Step(1, "unknown"), NotFoundErr)
+	assert.ErrorIs(t, e.Rewind(0, "unknown"), NotFoundErr)
+	assert.Error(t, e.Step(0))
+	assert.Error(t, e.Rewind(-1))
+}
+
+func TestReset(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	require.NoError(t, e.Register([]byte(testRecipe)))
+	require.NoError(t, e.Register([]byte(`spec:
+  name: test_histogram
+  type: histogram
+  labels:
+  - aaa
+  buckets:
+  - 1
+data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues:
+  - 0.5 2
+  - "3"
+`)))
+	collectors := make(map[string]prometheus.Collector)
+	for metName, exporter := range e.exporters {
+		collectors[metName] = exporter.collector()
+	}
+
+	before, err := registry.Gather()
+	require.NoError(t, err)
+	e.Update()
+	e.Update()
+	require.NoError(t, e.Reset("test_counter", "test_histogram"))
+	assert.Equal(t, 0, e.exporters["test_counter"].steps)
+	assert.Equal(t, 2, e.exporters["test_gauge"].steps)
+	assert.Equal(t, 3.0, testutil.ToFloat64(e.exporters["test_gauge"].collector()))
+
+	require.NoError(t, e.Reset())
+	after, err := registry.Gather()
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	// The metrics are reset in place.
+	for metName, exporter := range e.exporters {
+		assert.Same(t, collectors[metName], exporter.collector())
+	}
+
+	// The sequences are replayed from the beginning.
+	e.Update()
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.exporters["test_counter"].collector()))
+	assert.Equal(t, 5.0, testutil.ToFloat64(e.exporters["test_gauge"].collector()))
+	count, err := testutil.GatherAndCount(registry, "test_histogram")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.ErrorIs(t, e.Reset("unknown"), NotFoundErr)
+}
+
+func TestAppend(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	require.NoError(t, e.Register([]byte(testRecipe)))
+	require.NoError(t, e.Register([]byte(`spec:
+  name: test_hidden
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 _
+---
+spec:
+  name: test_histogram
+  type: histogram
+  labels:
+  - aaa
+  buckets:
+  - 1
+data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues:
+  - 0.5 2
+`)))
+	value := func(metName string, labels prometheus.Labels) float64 {
+		switch c := e.exporters[metName].metricExporter.(type) {
+		case *counterExporter:
+			return testutil.ToFloat64(c.counterVec.With(labels))
+		case *gaugeExporter:
+			return testutil.ToFloat64(c.gaugeVec.With(labels))
+		}
+		panic(metName)
+	}
+	foo := prometheus.Labels{"aaa": "foo"}
+	bar := prometheus.Labels{"aaa": "bar"}
+
+	for i := 0; i < 4; i++ {
+		e.Update()
+	}
+	require.NoError(t, e.Append("test_counter", []byte(`data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 4
+- labels:
+  - key: aaa
+    value: bar
+  sequence: 1 1
+`)))
+	require.NoError(t, e.AppendJSON("test_gauge", []byte(`{"data": [{"labels": [{"key": "aaa", "value": "foo"}], "sequence": "-2 3"}]}`)))
+	require.NoError(t, e.Append("test_hidden", []byte(`data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 2
+`)))
+	require.NoError(t, e.Append("test_histogram", []byte(`data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues:
+  - "3"
+`)))
+	assert.False(t, e.Exhausted())
+
+	// The recipes are padded for the steps after the data was exhausted.
+	assert.Equal(t, "1 2 0+0x1 4", e.exporters["test_counter"].recipe.Data[0].Sequence)
+	assert.Equal(t, "_x4 1 1", e.exporters["test_counter"].recipe.Data[1].Sequence)
+	assert.Equal(t, "5 3 1 1 -2 3", e.exporters["test_gauge"].recipe.Data[0].Sequence)
+	assert.Equal(t, "1 _ _x2 2", e.exporters["test_hidden"].recipe.Data[0].Sequence)
+	assert.Equal(t, []string{"0.5 2", "", "", "", "3"}, e.exporters["test_histogram"].recipe.Data[0].ObservedValues)
+
+	// The counters carry on from their current values.
+	assert.Equal(t, 3.0, value("test_counter", foo))
+	e.Update()
+	assert.Equal(t, 7.0, value("test_counter", foo))
+	assert.Equal(t, 1.0, value("test_counter", bar))
+	assert.Equal(t, -2.0, value("test_gauge", foo))
+	assert.Equal(t, 3.0, value("test_hidden", foo))
+	count, err := testutil.GatherAndCount(registry, "test_histogram")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Replaying the recipes reproduces the same values.
+	expected, err := registry.Gather()
+	require.NoError(t, err)
+	require.NoError(t, e.Rewind(0))
+	require.NoError(t, e.Step(5))
+	actual, err := registry.Gather()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	invalid := []struct {
+		desc    string
+		metName string
+		data    string
+	}{
+		{desc: "unknown metrics", metName: "unknown", data: "data: [{labels: [{key: aaa, value: foo}], sequence: '1'}]"},
+		{desc: "no data", metName: "test_counter", data: "data: []"},
+		{desc: "invalid label", metName: "test_counter", data: "data: [{labels: [{key: bbb, value: foo}], sequence: '1'}]"},
+		{desc: "negative counter value", metName: "test_counter", data: "data: [{labels: [{key: aaa, value: foo}], sequence: '-1'}]"},
+		{desc: "no value", metName: "test_histogram", data: "data: [{labels: [{key: aaa, value: foo}]}]"},
+		{desc: "duplicated labels", metName: "test_gauge",
+			data: "data: [{labels: [{key: aaa, value: foo}], sequence: '1'}, {labels: [{key: aaa, value: foo}], sequence: '2'}]"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.desc, func(t *testing.T) {
+			before := e.exporters["test_counter"].recipe.Data[0].Sequence
+			assert.Error(t, e.Append(tt.metName, []byte(tt.data)))
+			assert.Equal(t, before, e.exporters["test_counter"].recipe.Data[0].Sequence)
+		})
+	}
+	assert.ErrorIs(t, e.Append("unknown", []byte("data: []")), NotFoundErr)
+
+	// The appended data is kept by a snapshot, and dropped by a reset.
+	data, err := e.Snapshot()
+	require.NoError(t, err)
+	restored := New(prometheus.NewRegistry())
+	require.NoError(t, restored.Restore(data))
+	for _, r := range []*Exporter{e, restored} {
+		assert.Equal(t, "1 2 0+0x1 4", r.exporters["test_counter"].recipe.Data[0].Sequence)
+		require.NoError(t, r.Reset())
+		assert.Equal(t, "1 2", r.exporters["test_counter"].recipe.Data[0].Sequence)
+		assert.Len(t, r.exporters["test_counter"].recipe.Data, 1)
+		assert.Equal(t, "5 3 1", r.exporters["test_gauge"].recipe.Data[0].Sequence)
+		assert.Equal(t, []string{"0.5 2"}, r.exporters["test_histogram"].recipe.Data[0].ObservedValues)
+	}
+	for i := 0; i < 4; i++ {
+		e.Update()
+	}
+	assert.True(t, e.Exhausted())
+	assert.Equal(t, 3.0, value("test_counter", foo))
+	assert.Equal(t, 1.0, value("test_gauge", foo))
+	count, err = testutil.GatherAndCount(registry, "test_counter")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	e := New(prometheus.NewRegistry())
+	require.NoError(t, e.Register([]byte(testRecipe)))
+	e.Update()
+	e.Update()
+	data, err := e.Snapshot()
+	require.NoError(t, err)
+
+	// restore to an empty exporter
+	restored := New(prometheus.NewRegistry())
+	require.NoError(t, restored.Restore(data))
+	assert.Equal(t, 3.0, testutil.ToFloat64(restored.exporters["test_counter"].collector()))
+	assert.Equal(t, 3.0, testutil.ToFloat64(restored.exporters["test_gauge"].collector()))
+	restored.Update()
+	assert.Equal(t, 1.0, testutil.ToFloat64(restored.exporters["test_gauge"].collector()))
+
+	// the metrics already registered with the same recipe are advanced
+	restored = New(prometheus.NewRegistry())
+	require.NoError(t, restored.Register([]byte(testRecipe)))
+	require.NoError(t, restored.Restore(data))
+	assert.Equal(t, 3.0, testutil.ToFloat64(restored.exporters["test_counter"].collector()))
+	assert.Equal(t, 2, restored.exporters["test_gauge"].steps)
+}
+
+func TestParseExemplars(t *testing.T) {
+	value := 1.5
+	traceLabel := []label{{Key: "trace_id", Value: "abc"}}
+
+	cases := []struct {
+		desc      string
+		exemplars []exemplar
+		steps     int
+		expected  []*parsedExemplar
+		isError   bool
+	}{
+		{
+			desc:      "with and without exemplar",
+			exemplars: []exemplar{{Labels: traceLabel}, {}, {Labels: traceLabel, Value: &value}},
+			steps:     3,
+			expected: []*parsedExemplar{
+				{labels: prometheus.Labels{"trace_id": "abc"}},
+				nil,
+				{labels: prometheus.Labels{"trace_id": "abc"}, value: &value},
+			},
+		},
+		{
+			desc:      "more exemplars than steps",
+			exemplars: []exemplar{{Labels: traceLabel}, {Labels: traceLabel}},
+			steps:     1,
+			isError:   true,
+		},
+		{
+			desc:      "value without labels",
+			exemplars: []exemplar{{Value: &value}},
+			steps:     1,
+			isError:   true,
+		},
+		{
+			desc:      "invalid label name",
+			exemplars: []exemplar{{Labels: []label{{Key: "trace-id", Value: "abc"}}}},
+			steps:     1,
+			isError:   true,
+		},
+		{
+			desc:      "too long labels",
+			exemplars: []exemplar{{Labels: []label{{Key: "trace_id", Value: strings.Repeat("a", 128)}}}},
+			steps:     1,
+			isError:   true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.desc, func(t *testing.T) {
+			parsed, err := parseExemplars(tt.exemplars, tt.steps)
+			if tt.isError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, parsed)
+		})
+	}
+}
+
+func TestParseTimestamps(t *testing.T) {
+	absolute, err := time.Parse(time.RFC3339, "2023-06-01T12:34:56Z")
+	require.NoError(t, err)
+
+	cases := []struct {
+		desc       string
+		timestamps []string
+		steps      int
+		expected   []*parsedTimestamp
+		isError    bool
+	}{
+		{
+			desc:       "absolute, none and relative",
+			timestamps: []string{"2023-06-01T12:34:56Z", "", "-5m"},
+			steps:      3,
+			expected: []*parsedTimestamp{
+				{absolute: absolute},
+				nil,
+				{offset: -5 * time.Minute, relative: true},
+			},
+		},
+		{
+			desc:       "more timestamps than steps",
+			timestamps: []string{"1m", "2m"},
+			steps:      1,
+			isError:    true,
+		},
+		{
+			desc:       "invalid format",
+			timestamps: []string{"2023/06/01"},
+			steps:      1,
+			isError:    true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.desc, func(t *testing.T) {
+			parsed, err := parseTimestamps(tt.timestamps, tt.steps)
+			if tt.isError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, parsed)
+		})
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	require.NoError(t, e.Register([]byte(`spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2 3
+  timestamps:
+  - 2023-06-01T12:34:56Z
+  - -1h
+- labels:
+  - key: aaa
+    value: bar
+  sequence: 1 2 3
+`)))
+
+	timestampsOf := func() map[string]int64 {
+		t.Helper()
+		mfs, err := registry.Gather()
+		require.NoError(t, err)
+		require.Len(t, mfs, 1)
+		result := make(map[string]int64)
+		for _, m := range mfs[0].GetMetric() {
+			result[m.GetLabel()[0].GetValue()] = m.GetTimestampMs()
+		}
+		return result
+	}
+
+	e.Update()
+	assert.Equal(t, map[string]int64{"foo": 1685622896000, "bar": 0}, timestampsOf())
+
+	before := time.Now()
+	e.Update()
+	ts := timestampsOf()
+	assert.InDelta(t, before.Add(-time.Hour).UnixMilli(), ts["foo"], float64(time.Minute.Milliseconds()))
+	assert.Equal(t, int64(0), ts["bar"])
+
+	// no explicit timestamp for the last step
+	e.Update()
+	assert.Equal(t, map[string]int64{"foo": 0, "bar": 0}, timestampsOf())
+}
+
+func TestTemporality(t *testing.T) {
+	e := New(prometheus.NewRegistry())
+	require.NoError(t, e.Register([]byte(strings.Replace(testRecipe, "type: counter", "type: counter\n  temporality: delta", 1))))
+	assert.Equal(t, TemporalityDelta, e.Temporality("test_counter"))
+	assert.Equal(t, TemporalityCumulative, e.Temporality("test_gauge"))
+	assert.Equal(t, TemporalityCumulative, e.Temporality("unknown"))
+
+	// delta is invalid for gauge
+	e = New(prometheus.NewRegistry())
+	assert.Error(t, e.Register([]byte(strings.Replace(testRecipe, "type: gauge", "type: gauge\n  temporality: delta", 1))))
+}
+
+func TestSimulate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var nows []time.Time
+	var values []float64
+	err := Simulate([][]byte{[]byte(testRecipe)}, start, time.Minute, 0,
+		func(now time.Time, mfs []*dto.MetricFamily) error {
+			nows = append(nows, now)
+			for _, mf := range mfs {
+				if mf.GetName() == "test_gauge" {
+					values = append(values, mf.GetMetric()[0].GetGauge().GetValue())
+				}
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	// runs until the longest sequence is exhausted
+	assert.Equal(t, []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute)}, nows)
+	assert.Equal(t, []float64{5, 3, 1}, values)
+
+	count := 0
+	err = Simulate([][]byte{[]byte(testRecipe)}, start, time.Minute, 5,
+		func(now time.Time, mfs []*dto.MetricFamily) error {
+			count++
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	assert.Error(t, Simulate(nil, start, time.Minute, 0, nil))
+	assert.Error(t, Simulate([][]byte{[]byte(testRecipe)}, start, 0, 0, nil))
+}
+
+func TestRecipesFromSeries(t *testing.T) {
+	f := func(values ...float64) []*float64 {
+		result := make([]*float64, len(values))
+		for i := range values {
+			if !math.IsNaN(values[i]) {
+				result[i] = &values[i]
+			}
+		}
+		return result
+	}
+	missing := math.NaN()
+
+	recipes, err := RecipesFromSeries([]Series{
+		{Name: "test_total", Labels: map[string]string{"aaa": "foo"}, Values: f(1, 3, missing, 6)},
+		{Name: "test_gauge", Labels: map[string]string{"aaa": "foo"}, Values: f(missing, 5, 3)},
+		{Name: "test_total", Labels: map[string]string{"bbb": "bar"}, Values: f(2, 2)},
+		{Name: "test_reset_total", Labels: map[string]string{"aaa": "foo"}, Values: f(2, 1)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: _ 5 3
+---
+spec:
+  name: test_reset_total
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 2 1
+---
+spec:
+  name: test_total
+  type: counter
+  labels:
+  - aaa
+  - bbb
+data:
+- labels:
+  - key: aaa
+    value: foo
+  - key: bbb
+    value: ""
+  sequence: 1 2 _ 3
+- labels:
+  - key: aaa
+    value: ""
+  - key: bbb
+    value: bar
+  sequence: 2 0
+`, string(recipes))
+
+	// the result can be registered
+	e := New(prometheus.NewRegistry())
+	require.NoError(t, e.Register(recipes))
+
+	cases := []struct {
+		desc   string
+		series []Series
+	}{
+		{
+			desc:   "no labels",
+			series: []Series{{Name: "test", Values: f(1)}},
+		},
+		{
+			desc: "duplicated series",
+			series: []Series{
+				{Name: "test", Labels: map[string]string{"aaa": "foo"}, Values: f(1)},
+				{Name: "test", Labels: map[string]string{"aaa": "foo"}, Values: f(2)},
+			},
+		},
+		{
+			desc:   "no value",
+			series: []Series{{Name: "test", Labels: map[string]string{"aaa": "foo"}, Values: f(missing)}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := RecipesFromSeries(tc.series)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRegisterJSON(t *testing.T) {
+	single := `{"spec": {"name": "test_json", "type": "gauge", "labels": ["aaa"]},
+		"data": [{"labels": [{"key": "aaa", "value": "foo"}], "sequence": "1 2"}]}`
+	array := `[
+		{"spec": {"name": "test_json1", "type": "counter", "labels": ["aaa"]},
+		 "data": [{"labels": [{"key": "aaa", "value": "foo"}], "sequence": "1 2"}]},
+		{"spec": {"name": "test_json2", "type": "histogram", "labels": ["aaa"], "buckets": [1, 2]},
+		 "data": [{"labels": [{"key": "aaa", "value": "foo"}], "observedValues": ["0.5 1.5"]}]}
+	]`
+
+	cases := []struct {
+		desc     string
+		data     string
+		expected int
+		isError  bool
+	}{
+		{desc: "single recipe", data: single, expected: 1},
+		{desc: "array", data: "  \n" + array, expected: 2},
+		{desc: "broken", data: `{"spec": `, isError: true},
+		{desc: "invalid spec", data: `{"spec": {"name": "test_json", "type": "unknown", "labels": ["aaa"]}}`, isError: true},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			registry := prometheus.NewRegistry()
+			e := New(registry)
+			err := e.RegisterJSON([]byte(tc.data))
+			if tc.isError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			e.Update()
+			count, err := testutil.GatherAndCount(registry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, count)
+		})
+	}
+}
+
+// TestRecipeSchema checks that the published JSON Schema is in sync with the recipe format.
+func TestRecipeSchema(t *testing.T) {
+	data, err := os.ReadFile("../schema/recipe.schema.json")
+	require.NoError(t, err)
+	var schema struct {
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	for def, typ := range map[string]reflect.Type{
+		"recipe":         reflect.TypeOf(metricsRecipe{}),
+		"spec":           reflect.TypeOf(spec{}),
+		"data":           reflect.TypeOf(metricsData{}),
+		"label":          reflect.TypeOf(label{}),
+		"exemplar":       reflect.TypeOf(exemplar{}),
+		"expectation":    reflect.TypeOf(Expectation{}),
+		"expectedSample": reflect.TypeOf(ExpectedSample{}),
+	} {
+		fields := make([]string, 0)
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+		properties := make([]string, 0)
+		for name := range schema.Definitions[def].Properties {
+			properties = append(properties, name)
+		}
+		assert.ElementsMatch(t, fields, properties, def)
+	}
+}
+
+func TestRegisterCSV(t *testing.T) {
+	valid := `timestamp,test_gauge{aaa="foo"},test_gauge{bbb="bar"},"test_counter{aaa=""foo"",bbb=""bar""} counter"
+2023-01-01T00:00:00Z,1,,1
+-1m,2,3,2
+`
+
+	recipe, err := parseCSV(strings.NewReader(valid))
+	require.NoError(t, err)
+	require.Len(t, recipe, 2)
+	assert.Equal(t, spec{Name: "test_gauge", Type: "gauge", Labels: []string{"aaa", "bbb"}}, recipe[0].Spec)
+	assert.Equal(t, []metricsData{
+		{
+			Labels:     []label{{Key: "aaa", Value: "foo"}, {Key: "bbb", Value: ""}},
+			Sequence:   "1 2",
+			Timestamps: []string{"2023-01-01T00:00:00Z", "-1m"},
+		},
+		{
+			Labels:     []label{{Key: "aaa", Value: ""}, {Key: "bbb", Value: "bar"}},
+			Sequence:   "_ 3",
+			Timestamps: []string{"2023-01-01T00:00:00Z", "-1m"},
+		},
+	}, recipe[0].Data)
+	assert.Equal(t, spec{Name: "test_counter", Type: "counter", Labels: []string{"aaa", "bbb"}}, recipe[1].Spec)
+	assert.Equal(t, "1 2", recipe[1].Data[0].Sequence)
+
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	require.NoError(t, e.RegisterCSV(strings.NewReader(valid)))
+	assert.ErrorIs(t, e.RegisterCSV(strings.NewReader(valid)), ConflictErr)
+	e.Update()
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	cases := []struct {
+		desc string
+		data string
+	}{
+		{desc: "empty", data: ""},
+		{desc: "no row", data: "test{aaa=\"foo\"}\n"},
+		{desc: "no series", data: "timestamp\n-1m\n"},
+		{desc: "no labels", data: "test\n1\n"},
+		{desc: "invalid header", data: "test{aaa=}\n1\n"},
+		{desc: "inconsistent types", data: "test{aaa=\"foo\"},test{aaa=\"bar\"} counter\n1,1\n"},
+		{desc: "invalid value", data: "test{aaa=\"foo\"}\nabc\n"},
+		{desc: "wrong number of fields", data: "test{aaa=\"foo\"}\n1,2\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := New(prometheus.NewRegistry())
+			assert.Error(t, e.RegisterCSV(strings.NewReader(tc.data)))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Series is the absolute values of a series imported from another format.
+type Series struct {
+	Name   string
+	Labels map[string]string
+	// A nil value means a missing sample.
+	Values []*float64
+}
+
+// RecipesFromSeries builds the recipes which export the given values step by step.
+// The series whose name ends with "_total" are turned into counters by converting
+// the absolute values into the deltas, and the others are turned into gauges.
+// If a counter decreases, it is turned into a gauge instead because the reset cannot be expressed.
+// The missing samples are turned into the missing-sample markers ('_') of the sequence.
+// A label which only some of the series with the same name have is set to the empty value for the others.
+func RecipesFromSeries(series []Series) ([]byte, error) {
+	names := make([]string, 0)
+	byName := make(map[string][]Series)
+	for _, s := range series {
+		if _, ok := byName[s.Name]; !ok {
+			names = append(names, s.Name)
+		}
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+	sort.Strings(names)
+
+	recipes := make([]metricsRecipe, 0, len(names))
+	for _, name := range names {
+		recipe, err := recipeFromSeries(name, strings.HasSuffix(name, "_total"), byName[name])
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, *recipe)
+	}
+	return marshalRecipes(recipes)
+}
+
+// marshalRecipes marshals the recipes into a multi-document YAML.
+func marshalRecipes(recipes []metricsRecipe) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := range recipes {
+		doc, err := yaml.Marshal(&recipes[i])
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(doc)
+	}
+	return buf.Bytes(), nil
+}
+
+// recipeFromSeries builds a gauge recipe, or a counter recipe if counter is true.
+func recipeFromSeries(name string, counter bool, series []Series) (*metricsRecipe, error) {
+	labelSet := make(map[string]bool)
+	for _, s := range series {
+		for key := range s.Labels {
+			labelSet[key] = true
+		}
+	}
+	if len(labelSet) == 0 {
+		return nil, fmt.Errorf("%s: series without labels is not supported", name)
+	}
+	labelNames := make([]string, 0, len(labelSet))
+	for key := range labelSet {
+		labelNames = append(labelNames, key)
+	}
+	sort.Strings(labelNames)
+
+	metricsType := "gauge"
+	if counter {
+		metricsType = "counter"
+		for _, s := range series {
+			if !monotonic(s.Values) {
+				log.Printf("%s is imported as a gauge because it decreases", name)
+				metricsType = "gauge"
+				break
+			}
+		}
+	}
+
+	recipe := &metricsRecipe{
+		Spec: spec{
+			Name:   name,
+			Type:   metricsType,
+			Labels: labelNames,
+		},
+	}
+	seen := make(map[string]bool)
+	for _, s := range series {
+		var key strings.Builder
+		data := metricsData{}
+		for _, ln := range labelNames {
+			data.Labels = append(data.Labels, label{Key: ln, Value: s.Labels[ln]})
+			key.WriteString(ln + "=" + s.Labels[ln] + "\x00")
+		}
+		if seen[key.String()] {
+			return nil, fmt.Errorf("%s: duplicated series %v", name, s.Labels)
+		}
+		seen[key.String()] = true
+
+		tokens := make([]string, len(s.Values))
+		present := false
+		prev := 0.0
+		for j, v := range s.Values {
+			if v == nil {
+				tokens[j] = "_"
+				continue
+			}
+			present = true
+			value := *v
+			if metricsType == "counter" {
+				value -= prev
+				prev = *v
+			}
+			tokens[j] = strconv.FormatFloat(value, 'g', -1, 64)
+		}
+		if !present {
+			return nil, fmt.Errorf("%s: series %v has no value", name, s.Labels)
+		}
+		data.Sequence = strings.Join(tokens, " ")
+		recipe.Data = append(recipe.Data, data)
+	}
+	return recipe, nil
+}
+
+// monotonic reports whether the values can be exported by a counter starting from zero.
+// The missing samples are ignored.
+func monotonic(values []*float64) bool {
+	prev := 0.0
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		if *v < prev {
+			return false
+		}
+		prev = *v
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+package exporter
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// recordedFamily is the series of a metric family across the scrapes.
+type recordedFamily struct {
+	metricsType dto.MetricType
+	keys        []string
+	labels      map[string]map[string]string
+	// The metric of each series at each scrape. nil means the series is missing in the scrape.
+	metrics map[string][]*dto.Metric
+}
+
+// labeled reports whether any series of the family has a label.
+func (f *recordedFamily) labeled() bool {
+	for _, key := range f.keys {
+		if len(f.labels[key]) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func labelKey(m *dto.Metric) string {
+	var sb strings.Builder
+	for _, lp := range m.GetLabel() {
+		sb.WriteString(lp.GetName() + "=" + lp.GetValue() + "\x00")
+	}
+	return sb.String()
+}
+
+// RecipesFromScrapes builds the recipes which replay the scraped metric families step by step.
+// Each scrape becomes a step, and the type of each metric family is kept.
+// A counter which decreases is turned into a gauge because the reset cannot be expressed,
+// and untyped metrics are turned into gauges. Summaries are not supported and skipped.
+// The observed values of a histogram are reconstructed from the increase of the bucket counts,
+// so that they fall into the same buckets and their sum matches the increase of the sum as far as possible.
+func RecipesFromScrapes(scrapes [][]*dto.MetricFamily) ([]byte, error) {
+	names := make([]string, 0)
+	families := make(map[string]*recordedFamily)
+	for step, mfs := range scrapes {
+		for _, mf := range mfs {
+			f, ok := families[mf.GetName()]
+			if !ok {
+				f = &recordedFamily{
+					metricsType: mf.GetType(),
+					labels:      make(map[string]map[string]string),
+					metrics:     make(map[string][]*dto.Metric),
+				}
+				families[mf.GetName()] = f
+				names = append(names, mf.GetName())
+			} else if f.metricsType != mf.GetType() {
+				return nil, fmt.Errorf("%s: the type changed from %s to %s", mf.GetName(), f.metricsType, mf.GetType())
+			}
+
+			for _, m := range mf.GetMetric() {
+				key := labelKey(m)
+				if _, ok := f.metrics[key]; !ok {
+					f.keys = append(f.keys, key)
+					labels := make(map[string]string)
+					for _, lp := range m.GetLabel() {
+						labels[lp.GetName()] = lp.GetValue()
+					}
+					f.labels[key] = labels
+					f.metrics[key] = make([]*dto.Metric, len(scrapes))
+				}
+				f.metrics[key][step] = m
+			}
+		}
+	}
+	sort.Strings(names)
+
+	recipes := make([]metricsRecipe, 0, len(names))
+	for _, name := range names {
+		f := families[name]
+		if !f.labeled() {
+			log.Printf("%s is skipped because the metrics without labels are not supported", name)
+			continue
+		}
+		var recipe *metricsRecipe
+		var err error
+		switch f.metricsType {
+		case dto.MetricType_COUNTER, dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			series := make([]Series, 0, len(f.keys))
+			for _, key := range f.keys {
+				s := Series{
+					Name:   name,
+					Labels: f.labels[key],
+					Values: make([]*float64, len(scrapes)),
+				}
+				for step, m := range f.metrics[key] {
+					if m == nil {
+						continue
+					}
+					v := m.GetGauge().GetValue() + m.GetCounter().GetValue() + m.GetUntyped().GetValue()
+					s.Values[step] = &v
+				}
+				series = append(series, s)
+			}
+			recipe, err = recipeFromSeries(name, f.metricsType == dto.MetricType_COUNTER, series)
+		case dto.MetricType_HISTOGRAM:
+			recipe, err = histogramRecipe(name, f)
+		default:
+			log.Printf("%s is skipped because %s is not supported", name, f.metricsType)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, *recipe)
+	}
+	if len(recipes) == 0 {
+		return nil, fmt.Errorf("no metrics is recorded")
+	}
+	return marshalRecipes(recipes)
+}
+
+func histogramRecipe(name string, f *recordedFamily) (*metricsRecipe, error) {
+	var bounds []float64
+	labelSet := make(map[string]bool)
+	for _, key := range f.keys {
+		for ln := range f.labels[key] {
+			labelSet[ln] = true
+		}
+		for _, m := range f.metrics[key] {
+			if m == nil {
+				continue
+			}
+			var b []float64
+			for _, bucket := range m.GetHistogram().GetBucket() {
+				if !math.IsInf(bucket.GetUpperBound(), 1) {
+					b = append(b, bucket.GetUpperBound())
+				}
+			}
+			if bounds == nil {
+				bounds = b
+			} else if !equalBounds(bounds, b) {
+				return nil, fmt.Errorf("%s: the buckets are not the same in all of the series", name)
+			}
+		}
+	}
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("%s: histogram without buckets is not supported", name)
+	}
+	labelNames := make([]string, 0, len(labelSet))
+	for ln := range labelSet {
+		labelNames = append(labelNames, ln)
+	}
+	sort.Strings(labelNames)
+
+	recipe := &metricsRecipe{
+		Spec: spec{
+			Name:    name,
+			Type:    "histogram",
+			Labels:  labelNames,
+			Buckets: bounds,
+		},
+	}
+	for _, key := range f.keys {
+		data := metricsData{}
+		for _, ln := range labelNames {
+			data.Labels = append(data.Labels, label{Key: ln, Value: f.labels[key][ln]})
+		}
+		var prev *dto.Histogram
+		for _, m := range f.metrics[key] {
+			if m == nil {
+				data.ObservedValues = append(data.ObservedValues, "")
+				continue
+			}
+			cur := m.GetHistogram()
+			data.ObservedValues = append(data.ObservedValues, observations(name, bounds, prev, cur))
+			prev = cur
+		}
+		recipe.Data = append(recipe.Data, data)
+	}
+	return recipe, nil
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// observations reconstructs the values observed between the two states of a histogram
+// in the sequence notation. Every value in a bucket is placed at the same position
+// in the bucket, which is chosen so that the sum of the values matches the increase of the sum.
+func observations(name string, bounds []float64, prev, cur *dto.Histogram) string {
+	counts := bucketCounts(bounds, cur)
+	sum := cur.GetSampleSum()
+	if prev != nil {
+		prevCounts := bucketCounts(bounds, prev)
+		reset := false
+		for i := range counts {
+			if counts[i] < prevCounts[i] {
+				reset = true
+			}
+		}
+		if reset {
+			log.Printf("%s was reset, so the observations since the reset are replayed", name)
+		} else {
+			for i := range counts {
+				counts[i] -= prevCounts[i]
+			}
+			sum -= prev.GetSampleSum()
+		}
+	}
+
+	// The i-th bucket covers (lower[i], upper[i]]. The +Inf bucket is given a finite upper bound.
+	last := bounds[len(bounds)-1]
+	lower := make([]float64, len(counts))
+	upper := make([]float64, len(counts))
+	for i := range counts {
+		if i < len(bounds) {
+			upper[i] = bounds[i]
+		} else if last > 0 {
+			upper[i] = last * 2
+		} else {
+			upper[i] = last + 1
+		}
+		if i > 0 {
+			lower[i] = upper[i-1]
+		} else if bounds[0] > 0 {
+			lower[i] = 0
+		} else {
+			lower[i] = bounds[0] - 1
+		}
+	}
+
+	var minSum, width float64
+	for i, n := range counts {
+		minSum += float64(n) * lower[i]
+		width += float64(n) * (upper[i] - lower[i])
+	}
+	pos := 1.0
+	if width > 0 && !math.IsNaN(sum) {
+		pos = (sum - minSum) / width
+	}
+	// Keep the values inside the buckets.
+	pos = math.Max(0.001, math.Min(1, pos))
+
+	tokens := make([]string, 0)
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+		v := strconv.FormatFloat(lower[i]+pos*(upper[i]-lower[i]), 'f', -1, 64)
+		if n == 1 {
+			tokens = append(tokens, v)
+		} else {
+			tokens = append(tokens, fmt.Sprintf("%s+0x%d", v, n-1))
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// bucketCounts returns the non-cumulative count of each bucket followed by that of the +Inf bucket.
+func bucketCounts(bounds []float64, h *dto.Histogram) []uint64 {
+	counts := make([]uint64, len(bounds)+1)
+	var cumulative uint64
+	i := 0
+	for _, bucket := range h.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		counts[i] = bucket.GetCumulativeCount() - cumulative
+		cumulative = bucket.GetCumulativeCount()
+		i++
+	}
+	counts[len(bounds)] = h.GetSampleCount() - cumulative
+	return counts
+}
@@ -0,0 +1,51 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Simulate registers the recipes to a private registry and updates them
+// step by step without waiting, as if they were scraped every interval from start.
+// fn is called with the gathered metrics after each update.
+// If steps is 0, the simulation runs until all of the sequences are exhausted.
+func Simulate(recipes [][]byte, start time.Time, interval time.Duration, steps int,
+	fn func(now time.Time, mfs []*dto.MetricFamily) error) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval: %v", interval)
+	}
+	if steps < 0 {
+		return fmt.Errorf("invalid number of steps: %d", steps)
+	}
+
+	registry := prometheus.NewRegistry()
+	e := New(registry)
+	for _, yamlData := range recipes {
+		if err := e.Register(yamlData); err != nil {
+			return err
+		}
+	}
+	if len(e.exporters) == 0 {
+		return errors.New("no metrics is defined")
+	}
+
+	for i := 0; steps == 0 || i < steps; i++ {
+		now := start.Add(time.Duration(i) * interval)
+		e.UpdateAt(now)
+		mfs, err := registry.Gather()
+		if err != nil {
+			return err
+		}
+		if err := fn(now, mfs); err != nil {
+			return err
+		}
+		if steps == 0 && e.Exhausted() {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type snapshot struct {
+	Metrics []metricsSnapshot `yaml:"metrics"`
+}
+
+type metricsSnapshot struct {
+	Recipe metricsRecipe `yaml:"recipe"`
+	// The recipe as it was registered if data has been appended to it.
+	Initial *metricsRecipe `yaml:"initial,omitempty"`
+	// The number of updates applied to the metrics.
+	Steps int `yaml:"steps"`
+}
+
+// Snapshot returns the registered recipes and the position in their sequences.
+func (e *Exporter) Snapshot() ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make([]string, 0, len(e.exporters))
+	for metName := range e.exporters {
+		names = append(names, metName)
+	}
+	sort.Strings(names)
+
+	var ss snapshot
+	for _, metName := range names {
+		ss.Metrics = append(ss.Metrics, metricsSnapshot{
+			Recipe:  e.exporters[metName].recipe,
+			Initial: e.exporters[metName].initial,
+			Steps:   e.exporters[metName].steps,
+		})
+	}
+	return yaml.Marshal(&ss)
+}
+
+// Restore registers the recipes in the snapshot and advances them to the saved position.
+// Since the counter and histogram values are rebuilt by replaying the sequences,
+// they carry on from where they were.
+// If the metrics with the same recipe is already registered, only its position is restored.
+// If the recipe differs, the registered one is kept as it is.
+func (e *Exporter) Restore(data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var ss snapshot
+	if err := yaml.UnmarshalStrict(data, &ss); err != nil {
+		return err
+	}
+
+	for _, ms := range ss.Metrics {
+		metName := ms.Recipe.Spec.Name
+		if exporter, ok := e.exporters[metName]; ok {
+			same, err := sameRecipe(&exporter.recipe, &ms.Recipe)
+			if err != nil {
+				return err
+			}
+			if !same {
+				log.Printf("metrics %s was not restored because its recipe has been changed", metName)
+				continue
+			}
+		} else {
+			if err := e.register([]metricsRecipe{ms.Recipe}); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", metName, err)
+			}
+			e.exporters[metName].initial = ms.Initial
+		}
+
+		exporter := e.exporters[metName]
+		now := time.Now()
+		for exporter.steps < ms.Steps {
+			exporter.update(metName, now)
+			exporter.steps++
+		}
+		e.generation++
+		log.Printf("metrics %s was restored to step %d", metName, exporter.steps)
+	}
+	return nil
+}
+
+func sameRecipe(r1, r2 *metricsRecipe) (bool, error) {
+	b1, err := yaml.Marshal(r1)
+	if err != nil {
+		return false, err
+	}
+	b2, err := yaml.Marshal(r2)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(b1, b2), nil
+}
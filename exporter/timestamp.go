@@ -0,0 +1,134 @@
+package exporter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// parsedTimestamp is either an absolute time or an offset relative to the time of the update.
+type parsedTimestamp struct {
+	absolute time.Time
+	offset   time.Duration
+	relative bool
+}
+
+func (pt *parsedTimestamp) resolve(now time.Time) time.Time {
+	if pt.relative {
+		return now.Add(pt.offset)
+	}
+	return pt.absolute
+}
+
+// parseTimestamps parses the timestamp of each step, which is either
+// an RFC3339 time or a duration such as "-5m" relative to the time of the update.
+// An empty string means no explicit timestamp, which is represented by nil.
+func parseTimestamps(timestamps []string, steps int) ([]*parsedTimestamp, error) {
+	if len(timestamps) > steps {
+		return nil, fmt.Errorf("the number of timestamps %d exceeds that of steps %d", len(timestamps), steps)
+	}
+
+	result := make([]*parsedTimestamp, 0, len(timestamps))
+	for _, ts := range timestamps {
+		if ts == "" {
+			result = append(result, nil)
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			result = append(result, &parsedTimestamp{absolute: t})
+			continue
+		}
+		offset, err := time.ParseDuration(ts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %s: must be an RFC3339 time or a duration", ts)
+		}
+		result = append(result, &parsedTimestamp{offset: offset, relative: true})
+	}
+	return result, nil
+}
+
+// nextTimestamp returns the timestamp of the current step and consumes it.
+func (pmd *parsedMetricsData) nextTimestamp() *parsedTimestamp {
+	if len(pmd.timestamps) == 0 {
+		return nil
+	}
+	ts := pmd.timestamps[0]
+	pmd.timestamps = pmd.timestamps[1:]
+	return ts
+}
+
+// timestampCollector exports the metrics of the wrapped collector
+// with the explicit timestamp set for each label set.
+type timestampCollector struct {
+	collector prometheus.Collector
+	// The timestamps keyed by the signature of the labels.
+	timestamps map[uint64]time.Time
+	mu         sync.Mutex
+}
+
+func newTimestampCollector(collector prometheus.Collector) *timestampCollector {
+	return &timestampCollector{
+		collector:  collector,
+		timestamps: make(map[uint64]time.Time),
+	}
+}
+
+// set sets the timestamp of the label set. A nil timestamp clears it.
+func (tc *timestampCollector) set(labels map[string]string, pt *parsedTimestamp, now time.Time) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	signature := model.LabelsToSignature(labels)
+	if pt == nil {
+		delete(tc.timestamps, signature)
+		return
+	}
+	tc.timestamps[signature] = pt.resolve(now)
+}
+
+// reset clears all of the timestamps.
+func (tc *timestampCollector) reset() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.timestamps = make(map[uint64]time.Time)
+}
+
+func (tc *timestampCollector) Describe(ch chan<- *prometheus.Desc) {
+	tc.collector.Describe(ch)
+}
+
+func (tc *timestampCollector) Collect(ch chan<- prometheus.Metric) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if len(tc.timestamps) == 0 {
+		tc.collector.Collect(ch)
+		return
+	}
+
+	metricCh := make(chan prometheus.Metric)
+	go func() {
+		tc.collector.Collect(metricCh)
+		close(metricCh)
+	}()
+	for m := range metricCh {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			ch <- m
+			continue
+		}
+		labels := make(map[string]string)
+		for _, lp := range pb.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		if t, ok := tc.timestamps[model.LabelsToSignature(labels)]; ok {
+			m = prometheus.NewMetricWithTimestamp(t, m)
+		}
+		ch <- m
+	}
+}
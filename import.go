@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/promtool"
+	"github.com/peng225/any-exporter/queryrange"
+)
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "promtool", "format of the input file (promtool, query_range or csv)")
+	group := fs.Int("group", -1, "index of the promtool test group to import (-1 imports all of the groups)")
+	step := fs.Duration("step", 0, "step of the query_range result (0 infers it from the samples)")
+	name := fs.String("name", "", "metric name of the query_range result (default: __name__ of each series)")
+	output := fs.String("output", "-", "output file ('-' for the standard output)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import [flags] <file>\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("exactly one input file must be specified")
+	}
+	var recipes []byte
+	if *format == "csv" {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		recipes, err = exporter.ConvertCSV(f)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, recipes)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "promtool":
+		recipes, err = promtool.Import(data, *group)
+	case "query_range":
+		recipes, err = queryrange.Import(data, *step, *name)
+	default:
+		err = fmt.Errorf("unknown format: %s", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, recipes)
+}
+
+// writeOutput writes data to the file, or to the standard output if the file is "-".
+func writeOutput(file string, data []byte) error {
+	if file == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportTrailingFlags(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "recipe.yaml")
+	require.NoError(t, os.WriteFile(input, []byte("test{aaa=\"foo\"}\n1\n2\n"), 0o644))
+
+	// The flags after the input file are applied as well.
+	require.NoError(t, runImport([]string{input, "--format", "csv", "--output", output}))
+	recipes, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(recipes), "sequence: 1 2")
+
+	assert.Error(t, runImport([]string{input, "--format", "csv", input}))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/peng225/any-exporter/loader"
+	"github.com/peng225/any-exporter/promtool"
+)
+
+func runInputSeries(args []string) error {
+	fs := flag.NewFlagSet("input-series", flag.ExitOnError)
+	var recipes stringsFlag
+	fs.Var(&recipes, "recipe", "recipe file, directory or glob pattern to render (can be repeated)")
+	interval := fs.Duration("interval", time.Minute, "interval of the test group")
+	steps := fs.Int("steps", 0, "number of steps to render (0 renders until all of the sequences are exhausted)")
+	output := fs.String("output", "-", "output file ('-' for the standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(recipes) == 0 {
+		return errors.New("no recipe is specified")
+	}
+	data, err := loader.ReadAll(recipes)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return promtool.WriteTestGroup(w, data, *interval, *steps)
+}
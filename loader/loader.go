@@ -0,0 +1,131 @@
+package loader
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peng225/any-exporter/exporter"
+)
+
+var recipeExtensions = []string{".yaml", ".yml"}
+
+func isRecipeFile(name string) bool {
+	// Skip hidden files as well as the "..data" entries of a Kubernetes ConfigMap volume.
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, ext := range recipeExtensions {
+		if filepath.Ext(name) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// listDir returns the recipe files directly under dir.
+func listDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if !isRecipeFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Stat follows symlinks, which ConfigMap volumes consist of.
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// ExpandPaths resolves files, directories and glob patterns into
+// the list of recipe files. A directory is expanded to the recipe files
+// directly under it.
+func ExpandPaths(patterns []string) ([]string, error) {
+	files := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no recipe file matches %s", pattern)
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			var found []string
+			if info.IsDir() {
+				found, err = listDir(match)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				found = []string{match}
+			}
+			for _, f := range found {
+				if !seen[f] {
+					seen[f] = true
+					files = append(files, f)
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// ReadAll reads the recipe files found by ExpandPaths.
+func ReadAll(patterns []string) ([][]byte, error) {
+	files, err := ExpandPaths(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	recipes := make([][]byte, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, data)
+	}
+	return recipes, nil
+}
+
+// Load registers the recipes found by ExpandPaths to the exporter.
+func Load(e *exporter.Exporter, patterns []string) error {
+	files, err := ExpandPaths(patterns)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := e.Register(data); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		log.Printf("recipe %s was loaded", file)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: %s
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2 3
+`
+
+func writeRecipe(t *testing.T, path, name string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(recipe, name)), 0o644))
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeRecipe(t, filepath.Join(dir, "a.yaml"), "a")
+	writeRecipe(t, filepath.Join(dir, "b.yml"), "b")
+	writeRecipe(t, filepath.Join(dir, ".hidden.yaml"), "hidden")
+	writeRecipe(t, filepath.Join(dir, "sub", "c.yaml"), "c")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0o644))
+
+	cases := []struct {
+		desc     string
+		patterns []string
+		expected []string
+		isError  bool
+	}{
+		{
+			desc:     "file",
+			patterns: []string{filepath.Join(dir, "a.yaml")},
+			expected: []string{filepath.Join(dir, "a.yaml")},
+		},
+		{
+			desc:     "directory",
+			patterns: []string{dir},
+			expected: []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yml")},
+		},
+		{
+			desc:     "glob",
+			patterns: []string{filepath.Join(dir, "*", "*.yaml")},
+			expected: []string{filepath.Join(dir, "sub", "c.yaml")},
+		},
+		{
+			desc:     "duplicated",
+			patterns: []string{dir, filepath.Join(dir, "a.yaml")},
+			expected: []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yml")},
+		},
+		{
+			desc:     "not found",
+			patterns: []string{filepath.Join(dir, "notfound.yaml")},
+			isError:  true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.desc, func(t *testing.T) {
+			files, err := ExpandPaths(tt.patterns)
+			if tt.isError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, files)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeRecipe(t, filepath.Join(dir, "a.yaml"), "a")
+	writeRecipe(t, filepath.Join(dir, "b.yaml"), "b")
+
+	e := exporter.New(prometheus.NewRegistry())
+	require.NoError(t, Load(e, []string{dir}))
+
+	// The same metrics cannot be registered twice.
+	assert.ErrorIs(t, Load(e, []string{filepath.Join(dir, "a.yaml")}), exporter.ConflictErr)
+}
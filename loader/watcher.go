@@ -0,0 +1,120 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+)
+
+// Watcher polls a recipe directory and keeps the exporter in sync with it.
+// The metrics of a new file are registered and those of a deleted file are
+// removed. When a file is changed, the changed recipes are re-registered
+// from the beginning of their sequences.
+type Watcher struct {
+	exporter *exporter.Exporter
+	dir      string
+	interval time.Duration
+	// The recipes loaded from each file, keyed by the metrics name.
+	files map[string]map[string][]byte
+}
+
+func NewWatcher(e *exporter.Exporter, dir string, interval time.Duration) *Watcher {
+	return &Watcher{
+		exporter: e,
+		dir:      dir,
+		interval: interval,
+		files:    make(map[string]map[string][]byte),
+	}
+}
+
+// Sync applies the difference between the directory and the last synced state
+// to the exporter. The files which failed to be loaded are retried on the next sync.
+// All failures are logged and the first one is returned.
+func (w *Watcher) Sync() error {
+	paths, err := listDir(w.dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	current := make(map[string]map[string][]byte)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		recipes, err := exporter.SplitRecipes(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			// Keep the previous state so that the metrics are not lost by a broken edit.
+			if prev, ok := w.files[path]; ok {
+				current[path] = prev
+			}
+			continue
+		}
+		current[path] = recipes
+	}
+
+	// Remove the stale metrics first so that a recipe moved to another file can be registered.
+	toBeDeleted := make([]string, 0)
+	for path, prev := range w.files {
+		for name, doc := range prev {
+			if doc2, ok := current[path][name]; !ok || !bytes.Equal(doc, doc2) {
+				toBeDeleted = append(toBeDeleted, name)
+			}
+		}
+	}
+	sort.Strings(toBeDeleted)
+	w.exporter.Delete(toBeDeleted...)
+
+	for path, recipes := range current {
+		names := make([]string, 0, len(recipes))
+		for name := range recipes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if prev, ok := w.files[path][name]; ok && bytes.Equal(prev, recipes[name]) {
+				continue
+			}
+			if err := w.exporter.Register(recipes[name]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				delete(recipes, name)
+				continue
+			}
+			log.Printf("recipe %s in %s was loaded", name, path)
+		}
+	}
+	w.files = current
+
+	for _, err := range errs {
+		log.Println(err)
+	}
+	if len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Run calls Sync periodically until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Errors are already logged by Sync.
+			_ = w.Sync()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeRecipe(t, filepath.Join(dir, "a.yaml"), "a")
+
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	w := NewWatcher(e, dir, 0)
+	count := func() int {
+		t.Helper()
+		e.Update()
+		count, err := testutil.GatherAndCount(registry)
+		require.NoError(t, err)
+		return count
+	}
+
+	// initial load
+	require.NoError(t, w.Sync())
+	assert.Equal(t, 1, count())
+
+	// new file
+	writeRecipe(t, filepath.Join(dir, "b.yaml"), "b")
+	require.NoError(t, w.Sync())
+	assert.Equal(t, 2, count())
+
+	// broken file keeps the previous metrics
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("broken"), 0o644))
+	assert.Error(t, w.Sync())
+	assert.Equal(t, 2, count())
+	writeRecipe(t, filepath.Join(dir, "b.yaml"), "b")
+	require.NoError(t, w.Sync())
+
+	// changed file is re-registered from the beginning
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"),
+		[]byte(strings.Replace(fmt.Sprintf(recipe, "a"), "1 2 3", "10 20 30", 1)), 0o644))
+	require.NoError(t, w.Sync())
+	assert.Equal(t, 2, count())
+	mfs, err := registry.Gather()
+	require.NoError(t, err)
+	require.Equal(t, "a", mfs[0].GetName())
+	assert.Equal(t, 10.0, mfs[0].GetMetric()[0].GetGauge().GetValue())
+
+	// conflict with the metrics registered by others
+	require.NoError(t, e.Register([]byte(fmt.Sprintf(recipe, "c"))))
+	writeRecipe(t, filepath.Join(dir, "c.yaml"), "c")
+	assert.ErrorIs(t, w.Sync(), exporter.ConflictErr)
+	e.Delete("c")
+	require.NoError(t, w.Sync())
+	assert.Equal(t, 3, count())
+
+	// deleted file
+	require.NoError(t, os.Remove(filepath.Join(dir, "a.yaml")))
+	require.NoError(t, w.Sync())
+	assert.Equal(t, 2, count())
+}
+
+func TestWatcherInvalidData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "histogram.yaml")
+	histogram := `spec:
+  name: test_histogram
+  type: histogram
+  labels:
+  - aaa
+  buckets: [1]
+data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues: [%s]
+`
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(histogram, `"0.5"`)), 0o644))
+
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	w := NewWatcher(e, dir, 0)
+	require.NoError(t, w.Sync())
+
+	// the metrics which failed to be parsed are not left registered
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(histogram, `"abc"`)), 0o644))
+	assert.Error(t, w.Sync())
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(histogram, `"2"`)), 0o644))
+	require.NoError(t, w.Sync())
+	e.Update()
+	count, err := testutil.GatherAndCount(registry, "test_histogram")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -1,18 +1,119 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/loader"
+	"github.com/peng225/any-exporter/push"
+	"github.com/peng225/any-exporter/state"
 	"github.com/peng225/any-exporter/web"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// stringsFlag is a flag which can be specified multiple times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func newHTTPConfig(headers []string, username, passwordFile string,
+	retries int, timeout time.Duration) (push.HTTPConfig, error) {
+	if retries < 0 {
+		return push.HTTPConfig{}, fmt.Errorf("invalid number of retries: %d", retries)
+	}
+
+	hc := push.HTTPConfig{
+		Headers:      make(map[string]string),
+		Username:     username,
+		MaxRetries:   retries,
+		RetryBackoff: time.Second,
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return push.HTTPConfig{}, fmt.Errorf("invalid header: %s", h)
+		}
+		hc.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	if passwordFile != "" {
+		password, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return push.HTTPConfig{}, err
+		}
+		hc.Password = strings.TrimSpace(string(password))
+	}
+	return hc, nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if c := findCommand(os.Args[1]); c != nil {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	flag.Usage = func() {
+		printCommands(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
 	port := flag.Int("port", 8080, "listen port")
+	var recipes stringsFlag
+	flag.Var(&recipes, "recipe", "recipe file, directory or glob pattern to load at startup (can be repeated)")
+	recipeDir := flag.String("recipe-dir", "", "recipe directory to watch for changes")
+	recipeDirInterval := flag.Duration("recipe-dir-interval", 5*time.Second, "polling interval of the recipe directory")
+	stateFile := flag.String("state-file", "", "file to persist the registered recipes and their positions across restarts")
+	stateSaveInterval := flag.Duration("state-save-interval", time.Second, "interval to save the state file if the state has changed")
+	readTimeout := flag.Duration("read-timeout", 30*time.Second, "maximum duration for reading an entire request")
+	writeTimeout := flag.Duration("write-timeout", 30*time.Second, "maximum duration before timing out writes of a response")
+	idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "maximum duration to wait for the next request with keep-alives")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "maximum duration to drain the in-flight requests on shutdown")
+	maxRecipeSize := flag.Int64("max-recipe-size", 10<<20, "maximum size of a posted recipe in bytes")
+	enableOpenMetrics := flag.Bool("enable-openmetrics", false, "expose the metrics in the OpenMetrics format, in which exemplars are exposed, if it is requested")
+	pushInterval := flag.Duration("push-interval", 15*time.Second, "interval to update and push the metrics in push mode")
+	remoteWriteURL := flag.String("remote-write-url", "", "Prometheus remote-write endpoint to push the metrics to (enables push mode)")
+	var remoteWriteHeaders stringsFlag
+	flag.Var(&remoteWriteHeaders, "remote-write-header", "header added to remote-write requests in the 'Name: value' form (can be repeated)")
+	remoteWriteUsername := flag.String("remote-write-username", "", "username for the basic auth of remote write")
+	remoteWritePasswordFile := flag.String("remote-write-password-file", "", "file containing the password for the basic auth of remote write")
+	remoteWriteRetries := flag.Int("remote-write-retries", 3, "maximum number of retries of a failed remote write")
+	remoteWriteTimeout := flag.Duration("remote-write-timeout", 30*time.Second, "timeout of a remote-write request")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OTLP/HTTP endpoint such as http://localhost:4318 to push the metrics to (enables push mode)")
+	var otlpHeaders stringsFlag
+	flag.Var(&otlpHeaders, "otlp-header", "header added to OTLP requests in the 'Name: value' form (can be repeated)")
+	otlpRetries := flag.Int("otlp-retries", 3, "maximum number of retries of a failed OTLP request")
+	otlpTimeout := flag.Duration("otlp-timeout", 30*time.Second, "timeout of an OTLP request")
+	pushgatewayURL := flag.String("pushgateway-url", "", "Pushgateway to push the metrics to (enables push mode)")
+	pushgatewayJob := flag.String("pushgateway-job", "any-exporter", "job name used for the Pushgateway")
+	var pushgatewayGrouping stringsFlag
+	flag.Var(&pushgatewayGrouping, "pushgateway-grouping", "grouping key label for the Pushgateway in the 'name=value' form (can be repeated)")
+	pushgatewayAtEnd := flag.Bool("pushgateway-at-end", false, "push to the Pushgateway only once when all of the sequences are exhausted")
 
 	flag.Parse()
 
@@ -21,18 +122,152 @@ func main() {
 	if *port < 0 || *port > 65536 {
 		log.Fatalf("Invalid port number: %d", *port)
 	}
+	if *maxRecipeSize <= 0 {
+		log.Fatalf("Invalid max recipe size: %d", *maxRecipeSize)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	e := exporter.New(prometheus.DefaultRegisterer)
+	if len(recipes) != 0 {
+		if err := loader.Load(e, recipes); err != nil {
+			log.Fatalf("Failed to load recipes: %v", err)
+		}
+	}
+	if *recipeDir != "" {
+		if *recipeDirInterval <= 0 {
+			log.Fatalf("Invalid recipe directory polling interval: %v", *recipeDirInterval)
+		}
+		watcher := loader.NewWatcher(e, *recipeDir, *recipeDirInterval)
+		if err := watcher.Sync(); err != nil {
+			log.Fatalf("Failed to load recipes: %v", err)
+		}
+		go watcher.Run(ctx)
+	}
+	var saver *state.Saver
+	if *stateFile != "" {
+		if *stateSaveInterval <= 0 {
+			log.Fatalf("Invalid state save interval: %v", *stateSaveInterval)
+		}
+		saver = state.NewSaver(e, *stateFile, *stateSaveInterval)
+		if err := saver.Restore(); err != nil {
+			log.Fatalf("Failed to restore the state: %v", err)
+		}
+		go saver.Run(ctx)
+	}
+
+	var sinks []push.Sink
+	if *remoteWriteURL != "" {
+		hc, err := newHTTPConfig(remoteWriteHeaders, *remoteWriteUsername,
+			*remoteWritePasswordFile, *remoteWriteRetries, *remoteWriteTimeout)
+		if err != nil {
+			log.Fatalf("Invalid remote write settings: %v", err)
+		}
+		sinks = append(sinks, &push.RemoteWriteSink{
+			URL:        *remoteWriteURL,
+			HTTPConfig: hc,
+		})
+	}
+	if *otlpEndpoint != "" {
+		hc, err := newHTTPConfig(otlpHeaders, "", "", *otlpRetries, *otlpTimeout)
+		if err != nil {
+			log.Fatalf("Invalid OTLP settings: %v", err)
+		}
+		sinks = append(sinks, &push.OTLPSink{
+			Endpoint:    *otlpEndpoint,
+			HTTPConfig:  hc,
+			Temporality: e.Temporality,
+			Registered:  e.Registered,
+		})
+	}
+	if *pushgatewayURL != "" {
+		grouping := make(map[string]string)
+		for _, g := range pushgatewayGrouping {
+			name, value, ok := strings.Cut(g, "=")
+			if !ok {
+				log.Fatalf("Invalid Pushgateway grouping key: %s", g)
+			}
+			grouping[name] = value
+		}
+		sinks = append(sinks, &push.PushgatewaySink{
+			URL:       *pushgatewayURL,
+			Job:       *pushgatewayJob,
+			Grouping:  grouping,
+			OnlyAtEnd: *pushgatewayAtEnd,
+			Exhausted: e.Exhausted,
+		})
+	}
+	if len(sinks) != 0 {
+		if *pushInterval <= 0 {
+			log.Fatalf("Invalid push interval: %v", *pushInterval)
+		}
+		go push.Run(ctx, e, prometheus.DefaultGatherer, *pushInterval, sinks)
+	}
 
 	metricsHandler := web.MetricsHandler{
-		ChildHandler: promhttp.Handler(),
+		Exporter: e,
+		ChildHandler: promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+				// Exemplars are exposed only in the OpenMetrics format.
+				EnableOpenMetrics: *enableOpenMetrics,
+			}),
+		),
+		// In push mode, the metrics are updated by the push loop instead of scraping.
+		DisableUpdate: len(sinks) != 0,
+	}
+	recipeHandler := web.RecipeHandler{
+		Exporter:    e,
+		MaxBodySize: *maxRecipeSize,
+	}
+	recipeItemHandler := web.RecipeItemHandler{
+		Exporter:    e,
+		MaxBodySize: *maxRecipeSize,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+	mux.Handle("/recipe", recipeHandler)
+	mux.Handle("/recipe/", http.StripPrefix("/recipe/", recipeItemHandler))
+	mux.Handle("/status", web.StatusHandler{Exporter: e})
+	mux.Handle("/control/", http.StripPrefix("/control/", web.ControlHandler{Exporter: e}))
+	mux.HandleFunc("/health", web.HealthHandler)
+	mux.Handle(web.WorkspacePrefix, web.NewWorkspaceHandler(*maxRecipeSize, *enableOpenMetrics))
+
+	server := &http.Server{
+		Addr:         net.JoinHostPort("", strconv.Itoa(*port)),
+		Handler:      mux,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
 	}
 
-	http.Handle("/metrics", metricsHandler)
-	http.HandleFunc("/recipe", web.RecipeHandler)
-	http.HandleFunc("/health", web.HealthHandler)
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		log.Println("Shutting down.")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println(err)
+		}
+	}()
 
 	log.Printf("Start listening on port %d.", *port)
-	err := http.ListenAndServe(net.JoinHostPort("", strconv.Itoa(*port)), nil)
-	if err != nil {
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Println(err)
+		stop()
+	}
+
+	// ListenAndServe returns as soon as Shutdown is called,
+	// so wait for the in-flight requests to be drained.
+	<-shutdownDone
+	if saver != nil {
+		if err := saver.Save(); err != nil {
+			log.Fatalf("Failed to save the state: %v", err)
+		}
 	}
 }
@@ -0,0 +1,88 @@
+package promtool
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v2"
+)
+
+// testFile is the part of a promtool unit-test file which is needed to import the input series.
+type testFile struct {
+	Tests []TestGroup `yaml:"tests"`
+}
+
+// ImportSeries parses the input_series in a promtool unit-test file.
+// A single test group such as the output of WriteTestGroup is also accepted.
+// If group is negative, the input series of all of the test groups are imported.
+// Otherwise, only the group-th (0-origin) test group is imported.
+// The missing samples and the stale markers are returned as nil values.
+func ImportSeries(data []byte, group int) ([]exporter.Series, error) {
+	var tf testFile
+	if err := yaml.Unmarshal(data, &tf); err != nil {
+		return nil, err
+	}
+	if len(tf.Tests) == 0 {
+		var tg TestGroup
+		if err := yaml.Unmarshal(data, &tg); err != nil {
+			return nil, err
+		}
+		tf.Tests = []TestGroup{tg}
+	}
+
+	groups := tf.Tests
+	if group >= 0 {
+		if group >= len(tf.Tests) {
+			return nil, fmt.Errorf("test group %d not found", group)
+		}
+		groups = tf.Tests[group : group+1]
+	}
+
+	result := make([]exporter.Series, 0)
+	for _, tg := range groups {
+		for _, is := range tg.InputSeries {
+			lbls, values, err := parser.ParseSeriesDesc(is.Series + " " + is.Values)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", is.Series, err)
+			}
+			m := lbls.Map()
+			name := m[model.MetricNameLabel]
+			if name == "" {
+				return nil, fmt.Errorf("%s: metric name not found", is.Series)
+			}
+			delete(m, model.MetricNameLabel)
+			s := exporter.Series{
+				Name:   name,
+				Labels: m,
+				Values: make([]*float64, 0, len(values)),
+			}
+			for _, v := range values {
+				if v.Omitted || value.IsStaleNaN(v.Value) {
+					s.Values = append(s.Values, nil)
+					continue
+				}
+				v := v.Value
+				s.Values = append(s.Values, &v)
+			}
+			result = append(result, s)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no input series is found")
+	}
+	return result, nil
+}
+
+// Import converts the input_series in a promtool unit-test file into recipes.
+// See ImportSeries and exporter.RecipesFromSeries for the details.
+func Import(data []byte, group int) ([]byte, error) {
+	series, err := ImportSeries(data, group)
+	if err != nil {
+		return nil, err
+	}
+	return exporter.RecipesFromSeries(series)
+}
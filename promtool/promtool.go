@@ -0,0 +1,108 @@
+package promtool
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/sample"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"gopkg.in/yaml.v2"
+)
+
+// InputSeries is an entry of input_series in a promtool unit-test file.
+type InputSeries struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+// TestGroup is the part of a promtool test group which any-exporter can fill in.
+type TestGroup struct {
+	Interval    model.Duration `yaml:"interval"`
+	InputSeries []InputSeries  `yaml:"input_series"`
+}
+
+// seriesSelector formats the labels as a series selector such as `name{key="value"}`.
+func seriesSelector(lbls labels.Labels) string {
+	var sb strings.Builder
+	sb.WriteString(lbls.Get(model.MetricNameLabel))
+	sb.WriteByte('{')
+	first := true
+	lbls.Range(func(l labels.Label) {
+		if l.Name == model.MetricNameLabel {
+			return
+		}
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+		sb.WriteString(l.Name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(l.Value))
+	})
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Render simulates the recipes and returns the exported values as input_series.
+// The values are the ones exposed by the exporter, i.e. the counter deltas are
+// accumulated and the histograms are expanded into the _bucket, _sum and _count series.
+// A series which has not appeared yet at a step gets '_' for that step.
+// If steps is 0, the simulation runs until all of the sequences are exhausted.
+func Render(recipes [][]byte, interval time.Duration, steps int) (*TestGroup, error) {
+	keys := make([]string, 0)
+	values := make(map[string][]string)
+	step := 0
+	err := exporter.Simulate(recipes, time.Unix(0, 0), interval, steps, func(now time.Time, mfs []*dto.MetricFamily) error {
+		for _, s := range sample.FromMetricFamilies(mfs) {
+			key := seriesSelector(s.Labels)
+			if _, ok := values[key]; !ok {
+				keys = append(keys, key)
+				for i := 0; i < step; i++ {
+					values[key] = append(values[key], "_")
+				}
+			}
+			values[key] = append(values[key], strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+		step++
+		// Pad the series which disappeared at this step.
+		for _, key := range keys {
+			if len(values[key]) < step {
+				values[key] = append(values[key], "_")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tg := &TestGroup{
+		Interval: model.Duration(interval),
+	}
+	for _, key := range keys {
+		tg.InputSeries = append(tg.InputSeries, InputSeries{
+			Series: key,
+			Values: strings.Join(values[key], " "),
+		})
+	}
+	return tg, nil
+}
+
+// WriteTestGroup renders the recipes and writes the result in YAML.
+func WriteTestGroup(w io.Writer, recipes [][]byte, interval time.Duration, steps int) error {
+	tg, err := Render(recipes, interval, steps)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(tg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,121 @@
+package promtool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const recipe = `spec:
+  name: test_counter_total
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1+1x2
+---
+spec:
+  name: test_histogram
+  type: histogram
+  labels:
+  - aaa
+  buckets:
+  - 1
+  - 2
+data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues:
+  - 0.5 1.5
+  - "3"
+`
+
+func TestRender(t *testing.T) {
+	tg, err := Render([][]byte{[]byte(recipe)}, time.Minute, 0)
+	require.NoError(t, err)
+	assert.Equal(t, &TestGroup{
+		Interval: model.Duration(time.Minute),
+		InputSeries: []InputSeries{
+			{Series: `test_counter_total{aaa="foo"}`, Values: "1 3 6"},
+			{Series: `test_histogram_bucket{aaa="foo",le="1"}`, Values: "1 1 1"},
+			{Series: `test_histogram_bucket{aaa="foo",le="2"}`, Values: "2 2 2"},
+			{Series: `test_histogram_bucket{aaa="foo",le="+Inf"}`, Values: "2 3 3"},
+			{Series: `test_histogram_sum{aaa="foo"}`, Values: "2 5 5"},
+			{Series: `test_histogram_count{aaa="foo"}`, Values: "2 3 3"},
+		},
+	}, tg)
+
+	tg, err = Render([][]byte{[]byte(recipe)}, time.Minute, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "1", tg.InputSeries[0].Values)
+}
+
+func TestSeriesSelector(t *testing.T) {
+	assert.Equal(t, `foo{a="b",c="d\"e"}`,
+		seriesSelector(labels.FromStrings("__name__", "foo", "c", `d"e`, "a", "b")))
+	assert.Equal(t, `foo{}`, seriesSelector(labels.FromStrings("__name__", "foo")))
+}
+
+const unitTest = `rule_files:
+- rules.yaml
+tests:
+- interval: 1m
+  input_series:
+  - series: 'test_total{aaa="foo"}'
+    values: '0+10x2 _ 40'
+  - series: 'test_gauge{aaa="foo"}'
+    values: '1 stale 3'
+- interval: 1m
+  input_series:
+  - series: 'test_gauge{aaa="bar"}'
+    values: '5'
+`
+
+func TestImportSeries(t *testing.T) {
+	series, err := ImportSeries([]byte(unitTest), -1)
+	require.NoError(t, err)
+	require.Len(t, series, 3)
+	assert.Equal(t, "test_total", series[0].Name)
+	assert.Equal(t, map[string]string{"aaa": "foo"}, series[0].Labels)
+	require.Len(t, series[0].Values, 5)
+	assert.Nil(t, series[0].Values[3])
+	assert.Equal(t, 40.0, *series[0].Values[4])
+	// stale markers are missing samples
+	assert.Nil(t, series[1].Values[1])
+
+	series, err = ImportSeries([]byte(unitTest), 1)
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Equal(t, map[string]string{"aaa": "bar"}, series[0].Labels)
+
+	_, err = ImportSeries([]byte(unitTest), 2)
+	assert.Error(t, err)
+	_, err = ImportSeries([]byte(`tests: [{input_series: [{series: '{aaa="foo"}', values: '1'}]}]`), -1)
+	assert.Error(t, err)
+	_, err = ImportSeries([]byte(`tests: [{input_series: [{series: 'test{', values: '1'}]}]`), -1)
+	assert.Error(t, err)
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	tg, err := Render([][]byte{[]byte(recipe)}, time.Minute, 0)
+	require.NoError(t, err)
+	data, err := yaml.Marshal(tg)
+	require.NoError(t, err)
+
+	// the test group rendered by any-exporter can be imported.
+	// The histogram series are imported as gauges, so only the order changes.
+	recipes, err := Import(data, -1)
+	require.NoError(t, err)
+	imported, err := Render([][]byte{recipes}, time.Minute, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, tg.InputSeries, imported.InputSeries)
+}
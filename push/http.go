@@ -0,0 +1,89 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPConfig is the common configuration of the sinks pushing over HTTP.
+type HTTPConfig struct {
+	// Headers are added to every request.
+	Headers  map[string]string
+	Username string
+	Password string
+	// MaxRetries is the maximum number of retries on a recoverable error.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	Client       *http.Client
+}
+
+// recoverableError is an error which is worth retrying,
+// such as a network error, 5xx or 429 response.
+type recoverableError struct {
+	error
+}
+
+// post sends the body with retries.
+func (hc *HTTPConfig) post(ctx context.Context, url string, body []byte, header http.Header) error {
+	backoff := hc.RetryBackoff
+	for i := 0; ; i++ {
+		err := hc.send(ctx, url, body, header)
+		if err == nil {
+			return nil
+		}
+		var re recoverableError
+		if !errors.As(err, &re) || i >= hc.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (hc *HTTPConfig) send(ctx context.Context, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("User-Agent", "any-exporter")
+	for k, v := range hc.Headers {
+		req.Header.Set(k, v)
+	}
+	if hc.Username != "" {
+		req.SetBasicAuth(hc.Username, hc.Password)
+	}
+
+	client := hc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("push failed with status %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
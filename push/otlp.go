@@ -0,0 +1,271 @@
+package push
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPSink sends the metrics to an OTLP/HTTP endpoint.
+// Counters, gauges and histograms are mapped to OTLP Sum, Gauge and Histogram respectively.
+type OTLPSink struct {
+	// Endpoint is the base URL such as http://localhost:4318.
+	// The metrics are sent to its /v1/metrics path.
+	Endpoint string
+	HTTPConfig
+	// Temporality returns the aggregation temporality of the metrics.
+	Temporality func(metricsName string) string
+	// Registered reports whether the metrics is registered. The state of a series which is not
+	// gathered is kept while its metrics is registered, so that a series hidden by missing samples
+	// carries on when it appears again. If nil, the state is dropped as soon as the series is not gathered.
+	Registered func(metricsName string) bool
+
+	series map[otlpSeriesKey]*otlpSeries
+}
+
+type otlpSeriesKey struct {
+	name      string
+	signature uint64
+}
+
+// otlpSeries holds the state of a series to calculate the start time and the delta.
+type otlpSeries struct {
+	start    time.Time
+	lastPush time.Time
+	last     *dto.Metric
+}
+
+func (ot *OTLPSink) Name() string {
+	return ot.Endpoint
+}
+
+func (ot *OTLPSink) Push(ctx context.Context, mfs []*dto.MetricFamily, now time.Time) error {
+	data, err := proto.Marshal(ot.buildRequest(mfs, now))
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/x-protobuf")
+	return ot.post(ctx, strings.TrimSuffix(ot.Endpoint, "/")+"/v1/metrics", data, header)
+}
+
+func (ot *OTLPSink) temporality(metricsName string) metricspb.AggregationTemporality {
+	if ot.Temporality != nil && ot.Temporality(metricsName) == exporter.TemporalityDelta {
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	}
+	return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+}
+
+// track returns the state of the series before this push and records the current one.
+// A series which is new, e.g. registered again after it was deleted, or whose value went back,
+// e.g. by a reset or a rewind, is restarted from now.
+func (ot *OTLPSink) track(name string, m *dto.Metric, now time.Time, seen map[otlpSeriesKey]bool) otlpSeries {
+	labels := make(map[string]string)
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	key := otlpSeriesKey{
+		name:      name,
+		signature: model.LabelsToSignature(labels),
+	}
+	seen[key] = true
+
+	s, ok := ot.series[key]
+	if !ok || restarted(s.last, m) {
+		s = &otlpSeries{
+			start:    now,
+			lastPush: now,
+		}
+		ot.series[key] = s
+	}
+	prev := *s
+	s.lastPush = now
+	s.last = m
+	return prev
+}
+
+// restarted reports whether the counter or histogram went back from last to m.
+func restarted(last, m *dto.Metric) bool {
+	if last == nil {
+		return false
+	}
+	if m.GetCounter().GetValue() < last.GetCounter().GetValue() ||
+		m.GetHistogram().GetSampleCount() < last.GetHistogram().GetSampleCount() {
+		return true
+	}
+	lastBuckets := last.GetHistogram().GetBucket()
+	for i, b := range m.GetHistogram().GetBucket() {
+		if i < len(lastBuckets) && b.GetCumulativeCount() < lastBuckets[i].GetCumulativeCount() {
+			return true
+		}
+	}
+	return false
+}
+
+func attributes(m *dto.Metric) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key: lp.GetName(),
+			Value: &commonpb.AnyValue{
+				Value: &commonpb.AnyValue_StringValue{StringValue: lp.GetValue()},
+			},
+		})
+	}
+	return attrs
+}
+
+func timeOf(m *dto.Metric, now time.Time) uint64 {
+	if m.GetTimestampMs() != 0 {
+		return uint64(time.UnixMilli(m.GetTimestampMs()).UnixNano())
+	}
+	return uint64(now.UnixNano())
+}
+
+func (ot *OTLPSink) buildRequest(mfs []*dto.MetricFamily, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	if ot.series == nil {
+		ot.series = make(map[otlpSeriesKey]*otlpSeries)
+	}
+	seen := make(map[otlpSeriesKey]bool)
+	metrics := make([]*metricspb.Metric, 0, len(mfs))
+	for _, mf := range mfs {
+		name := mf.GetName()
+		metric := &metricspb.Metric{
+			Name:        name,
+			Description: mf.GetHelp(),
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			temporality := ot.temporality(name)
+			sum := &metricspb.Sum{
+				AggregationTemporality: temporality,
+				IsMonotonic:            true,
+			}
+			for _, m := range mf.GetMetric() {
+				prev := ot.track(name, m, now, seen)
+				value := m.GetCounter().GetValue()
+				start := prev.start
+				if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+					value -= prev.last.GetCounter().GetValue()
+					start = prev.lastPush
+				}
+				sum.DataPoints = append(sum.DataPoints, &metricspb.NumberDataPoint{
+					Attributes:        attributes(m),
+					StartTimeUnixNano: uint64(start.UnixNano()),
+					TimeUnixNano:      timeOf(m, now),
+					Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+				})
+			}
+			metric.Data = &metricspb.Metric_Sum{Sum: sum}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &metricspb.Gauge{}
+			for _, m := range mf.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+					Attributes:   attributes(m),
+					TimeUnixNano: timeOf(m, now),
+					Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+				})
+			}
+			metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+		case dto.MetricType_HISTOGRAM:
+			temporality := ot.temporality(name)
+			histogram := &metricspb.Histogram{
+				AggregationTemporality: temporality,
+			}
+			for _, m := range mf.GetMetric() {
+				prev := ot.track(name, m, now, seen)
+				dp := histogramDataPoint(m.GetHistogram())
+				dp.Attributes = attributes(m)
+				dp.StartTimeUnixNano = uint64(prev.start.UnixNano())
+				dp.TimeUnixNano = timeOf(m, now)
+				if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+					dp.StartTimeUnixNano = uint64(prev.lastPush.UnixNano())
+					if prev.last != nil {
+						prevDp := histogramDataPoint(prev.last.GetHistogram())
+						dp.Count -= prevDp.Count
+						*dp.Sum -= *prevDp.Sum
+						for i := range dp.BucketCounts {
+							dp.BucketCounts[i] -= prevDp.BucketCounts[i]
+						}
+					}
+				}
+				histogram.DataPoints = append(histogram.DataPoints, dp)
+			}
+			metric.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+		default:
+			// Summaries are not supported.
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	// Forget the series of the deleted metrics so that they are restarted if they come back.
+	for key := range ot.series {
+		if !seen[key] && (ot.Registered == nil || !ot.Registered(key.name)) {
+			delete(ot.series, key)
+		}
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{
+						{
+							Key: "service.name",
+							Value: &commonpb.AnyValue{
+								Value: &commonpb.AnyValue_StringValue{StringValue: "any-exporter"},
+							},
+						},
+					},
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope: &commonpb.InstrumentationScope{
+							Name: "any-exporter",
+						},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+// histogramDataPoint converts the cumulative buckets of Prometheus
+// into the explicit bounds and the per-bucket counts of OTLP.
+func histogramDataPoint(h *dto.Histogram) *metricspb.HistogramDataPoint {
+	sum := h.GetSampleSum()
+	dp := &metricspb.HistogramDataPoint{
+		Count: h.GetSampleCount(),
+		Sum:   &sum,
+	}
+	var prevCount uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), +1) {
+			continue
+		}
+		dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+		dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-prevCount)
+		prevCount = b.GetCumulativeCount()
+	}
+	// The overflow bucket
+	dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-prevCount)
+	return dp
+}
@@ -0,0 +1,232 @@
+package push
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const otlpRecipe = `spec:
+  name: test_counter_total
+  type: counter
+  temporality: delta
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2
+---
+spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 5 3
+---
+spec:
+  name: test_histogram
+  type: histogram
+  labels:
+  - aaa
+  buckets: [1, 2]
+data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues:
+  - 0.5 1.5 3
+  - 0.5
+`
+
+func TestOTLP(t *testing.T) {
+	var requests []*colmetricspb.ExportMetricsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var req colmetricspb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(data, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, &req)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	require.NoError(t, e.Register([]byte(otlpRecipe)))
+
+	sink := &OTLPSink{
+		Endpoint:    server.URL,
+		Temporality: e.Temporality,
+	}
+	start := time.Unix(1685577600, 0)
+	tick(context.Background(), e, registry, start, []Sink{sink})
+	tick(context.Background(), e, registry, start.Add(time.Minute), []Sink{sink})
+	require.Len(t, requests, 2)
+
+	metrics := make(map[string]*metricspb.Metric)
+	for _, m := range requests[1].ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	require.Len(t, metrics, 3)
+
+	// delta counter
+	sum := metrics["test_counter_total"].GetSum()
+	assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, sum.AggregationTemporality)
+	assert.True(t, sum.IsMonotonic)
+	assert.Equal(t, 2.0, sum.DataPoints[0].GetAsDouble())
+	assert.Equal(t, uint64(start.UnixNano()), sum.DataPoints[0].StartTimeUnixNano)
+	assert.Equal(t, uint64(start.Add(time.Minute).UnixNano()), sum.DataPoints[0].TimeUnixNano)
+	assert.Equal(t, "aaa", sum.DataPoints[0].Attributes[0].Key)
+	assert.Equal(t, "foo", sum.DataPoints[0].Attributes[0].Value.GetStringValue())
+
+	// gauge
+	assert.Equal(t, 3.0, metrics["test_gauge"].GetGauge().DataPoints[0].GetAsDouble())
+
+	// cumulative histogram
+	histogram := metrics["test_histogram"].GetHistogram()
+	assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, histogram.AggregationTemporality)
+	dp := histogram.DataPoints[0]
+	assert.Equal(t, uint64(start.UnixNano()), dp.StartTimeUnixNano)
+	assert.Equal(t, uint64(4), dp.Count)
+	assert.Equal(t, 5.5, dp.GetSum())
+	assert.Equal(t, []float64{1, 2}, dp.ExplicitBounds)
+	assert.Equal(t, []uint64{2, 1, 1}, dp.BucketCounts)
+}
+
+const otlpRestartRecipe = `spec:
+  name: test_delta_total
+  type: counter
+  temporality: delta
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 5 5 5
+---
+spec:
+  name: test_cumulative_total
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 5 5 5
+`
+
+func TestOTLPRestart(t *testing.T) {
+	var requests []*colmetricspb.ExportMetricsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var req colmetricspb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(data, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, &req)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	require.NoError(t, e.Register([]byte(otlpRestartRecipe)))
+
+	sink := &OTLPSink{
+		Endpoint:    server.URL,
+		Temporality: e.Temporality,
+		Registered:  e.Registered,
+	}
+	// lastPush returns the data point of each counter in the last request.
+	lastPush := func() map[string]*metricspb.NumberDataPoint {
+		result := make(map[string]*metricspb.NumberDataPoint)
+		for _, m := range requests[len(requests)-1].ResourceMetrics[0].ScopeMetrics[0].Metrics {
+			result[m.Name] = m.GetSum().DataPoints[0]
+		}
+		return result
+	}
+
+	start := time.Unix(1685577600, 0)
+	tick(context.Background(), e, registry, start, []Sink{sink})
+	tick(context.Background(), e, registry, start.Add(time.Minute), []Sink{sink})
+	dps := lastPush()
+	assert.Equal(t, 5.0, dps["test_delta_total"].GetAsDouble())
+	assert.Equal(t, 10.0, dps["test_cumulative_total"].GetAsDouble())
+
+	// The value going back by a reset restarts the series.
+	require.NoError(t, e.Reset())
+	now := start.Add(2 * time.Minute)
+	tick(context.Background(), e, registry, now, []Sink{sink})
+	dps = lastPush()
+	assert.Equal(t, 5.0, dps["test_delta_total"].GetAsDouble())
+	assert.Equal(t, uint64(now.UnixNano()), dps["test_delta_total"].StartTimeUnixNano)
+	assert.Equal(t, 5.0, dps["test_cumulative_total"].GetAsDouble())
+	assert.Equal(t, uint64(now.UnixNano()), dps["test_cumulative_total"].StartTimeUnixNano)
+
+	// A series which disappears and comes back is restarted as well.
+	e.Delete("test_delta_total")
+	tick(context.Background(), e, registry, now.Add(time.Minute), []Sink{sink})
+	require.NoError(t, e.Register([]byte(otlpRestartRecipe[:strings.Index(otlpRestartRecipe, "---")])))
+	now = now.Add(2 * time.Minute)
+	tick(context.Background(), e, registry, now, []Sink{sink})
+	dps = lastPush()
+	assert.Equal(t, 5.0, dps["test_delta_total"].GetAsDouble())
+	assert.Equal(t, uint64(now.UnixNano()), dps["test_delta_total"].StartTimeUnixNano)
+	require.Len(t, requests, 5)
+
+	// A series hidden by a missing sample carries on from its last value.
+	require.NoError(t, e.Register([]byte(`spec:
+  name: test_gap_total
+  type: counter
+  temporality: delta
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 _ 2
+`)))
+	gapStart := now.Add(time.Minute)
+	tick(context.Background(), e, registry, gapStart, []Sink{sink})
+	assert.Equal(t, 1.0, lastPush()["test_gap_total"].GetAsDouble())
+	tick(context.Background(), e, registry, gapStart.Add(time.Minute), []Sink{sink})
+	assert.NotContains(t, lastPush(), "test_gap_total")
+	tick(context.Background(), e, registry, gapStart.Add(2*time.Minute), []Sink{sink})
+	dp := lastPush()["test_gap_total"]
+	assert.Equal(t, 2.0, dp.GetAsDouble())
+	assert.Equal(t, uint64(gapStart.UnixNano()), dp.StartTimeUnixNano)
+}
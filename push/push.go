@@ -0,0 +1,56 @@
+package push
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sink is a destination to which the metrics are pushed.
+type Sink interface {
+	Name() string
+	Push(ctx context.Context, mfs []*dto.MetricFamily, now time.Time) error
+}
+
+// Run updates the exporter and pushes the gathered metrics to the sinks
+// on each tick until ctx is done. Only the metrics registered to the exporter are pushed,
+// so the other metrics in the gatherer, e.g. the Go runtime metrics, are not.
+func Run(ctx context.Context, e *exporter.Exporter, gatherer prometheus.Gatherer,
+	interval time.Duration, sinks []Sink) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			tick(ctx, e, gatherer, now, sinks)
+		}
+	}
+}
+
+func tick(ctx context.Context, e *exporter.Exporter, gatherer prometheus.Gatherer,
+	now time.Time, sinks []Sink) {
+	e.Update()
+	mfs, err := gatherer.Gather()
+	if err != nil {
+		log.Printf("failed to gather metrics: %v", err)
+		return
+	}
+	registered := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		if e.Registered(mf.GetName()) {
+			registered = append(registered, mf)
+		}
+	}
+	mfs = registered
+	for _, sink := range sinks {
+		if err := sink.Push(ctx, mfs, now); err != nil {
+			log.Printf("failed to push metrics to %s: %v", sink.Name(), err)
+		}
+	}
+}
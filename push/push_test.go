@@ -0,0 +1,42 @@
+package push
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sinkFunc is a Sink which calls the function with the pushed metrics.
+type sinkFunc func(mfs []*dto.MetricFamily)
+
+func (f sinkFunc) Name() string {
+	return "test"
+}
+
+func (f sinkFunc) Push(ctx context.Context, mfs []*dto.MetricFamily, now time.Time) error {
+	f(mfs)
+	return nil
+}
+
+func TestTickPushesOnlyRegisteredMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector())
+	e := exporter.New(registry)
+	require.NoError(t, e.Register([]byte(recipe)))
+
+	var names []string
+	sink := sinkFunc(func(mfs []*dto.MetricFamily) {
+		for _, mf := range mfs {
+			names = append(names, mf.GetName())
+		}
+	})
+	tick(context.Background(), e, registry, time.Now(), []Sink{sink})
+	assert.Equal(t, []string{"test_counter_total", "test_histogram"}, names)
+}
@@ -0,0 +1,83 @@
+package push
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	pushgateway "github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// PushgatewaySink pushes the metrics to a Pushgateway.
+// The metrics in the group identified by the job and the grouping key
+// are replaced on every push.
+type PushgatewaySink struct {
+	URL      string
+	Job      string
+	Grouping map[string]string
+	// OnlyAtEnd makes the sink push only once when all of the sequences are exhausted,
+	// as a batch job does when it finishes.
+	OnlyAtEnd bool
+	// Exhausted reports whether all of the sequences are exhausted. Required if OnlyAtEnd is set.
+	Exhausted func() bool
+	Client    *http.Client
+
+	pushedAtEnd bool
+}
+
+func (pg *PushgatewaySink) Name() string {
+	return pg.URL
+}
+
+func (pg *PushgatewaySink) Push(ctx context.Context, mfs []*dto.MetricFamily, now time.Time) error {
+	if pg.OnlyAtEnd {
+		if !pg.Exhausted() {
+			// Push again when newly registered sequences are exhausted.
+			pg.pushedAtEnd = false
+			return nil
+		}
+		if pg.pushedAtEnd {
+			return nil
+		}
+	}
+
+	pusher := pushgateway.New(pg.URL, pg.Job).Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return withoutTimestamps(mfs), nil
+	}))
+	keys := make([]string, 0, len(pg.Grouping))
+	for k := range pg.Grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pusher = pusher.Grouping(k, pg.Grouping[k])
+	}
+	if pg.Client != nil {
+		pusher = pusher.Client(pg.Client)
+	}
+
+	if err := pusher.PushContext(ctx); err != nil {
+		return err
+	}
+	if pg.OnlyAtEnd {
+		pg.pushedAtEnd = true
+	}
+	return nil
+}
+
+// withoutTimestamps removes the explicit timestamps, which the Pushgateway rejects.
+func withoutTimestamps(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	result := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		mf = proto.Clone(mf).(*dto.MetricFamily)
+		for _, m := range mf.GetMetric() {
+			m.TimestampMs = nil
+		}
+		result = append(result, mf)
+	}
+	return result
+}
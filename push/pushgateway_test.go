@@ -0,0 +1,78 @@
+package push
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushgateway(t *testing.T) {
+	var paths []string
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		desc      string
+		onlyAtEnd bool
+		pushes    int
+	}{
+		{
+			desc:      "every tick",
+			onlyAtEnd: false,
+			pushes:    3,
+		},
+		{
+			desc:      "at the end",
+			onlyAtEnd: true,
+			pushes:    1,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.desc, func(t *testing.T) {
+			paths = nil
+			bodies = nil
+
+			registry := prometheus.NewRegistry()
+			e := exporter.New(registry)
+			require.NoError(t, e.Register([]byte(recipe)))
+
+			sink := &PushgatewaySink{
+				URL:       server.URL,
+				Job:       "batch",
+				Grouping:  map[string]string{"instance": "foo"},
+				OnlyAtEnd: tt.onlyAtEnd,
+				Exhausted: e.Exhausted,
+			}
+			for i := 0; i < 3; i++ {
+				tick(context.Background(), e, registry, time.Now(), []Sink{sink})
+			}
+
+			require.Len(t, paths, tt.pushes)
+			assert.Equal(t, "/metrics/job/batch/instance/foo", paths[0])
+			assert.NotEmpty(t, bodies[0])
+		})
+	}
+}
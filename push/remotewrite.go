@@ -0,0 +1,75 @@
+package push
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/peng225/any-exporter/sample"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// RemoteWriteSink sends the samples to a Prometheus remote-write endpoint.
+type RemoteWriteSink struct {
+	URL string
+	HTTPConfig
+}
+
+func (rw *RemoteWriteSink) Name() string {
+	return rw.URL
+}
+
+func (rw *RemoteWriteSink) Push(ctx context.Context, mfs []*dto.MetricFamily, now time.Time) error {
+	data, err := buildWriteRequest(mfs, now).Marshal()
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Encoding", "snappy")
+	header.Set("Content-Type", "application/x-protobuf")
+	header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return rw.post(ctx, rw.URL, snappy.Encode(nil, data), header)
+}
+
+var metadataTypes = map[dto.MetricType]prompb.MetricMetadata_MetricType{
+	dto.MetricType_COUNTER:   prompb.MetricMetadata_COUNTER,
+	dto.MetricType_GAUGE:     prompb.MetricMetadata_GAUGE,
+	dto.MetricType_HISTOGRAM: prompb.MetricMetadata_HISTOGRAM,
+	dto.MetricType_SUMMARY:   prompb.MetricMetadata_SUMMARY,
+	dto.MetricType_UNTYPED:   prompb.MetricMetadata_UNKNOWN,
+}
+
+// buildWriteRequest converts the metric families into a remote-write request.
+// The samples without an explicit timestamp are stamped with now.
+func buildWriteRequest(mfs []*dto.MetricFamily, now time.Time) *prompb.WriteRequest {
+	req := &prompb.WriteRequest{}
+	for _, s := range sample.FromMetricFamilies(mfs) {
+		ts := s.TimestampMs
+		if ts == 0 {
+			ts = now.UnixMilli()
+		}
+		series := prompb.TimeSeries{
+			Samples: []prompb.Sample{{Value: s.Value, Timestamp: ts}},
+		}
+		s.Labels.Range(func(l labels.Label) {
+			series.Labels = append(series.Labels, prompb.Label{Name: l.Name, Value: l.Value})
+		})
+		req.Timeseries = append(req.Timeseries, series)
+	}
+	for _, mf := range mfs {
+		req.Metadata = append(req.Metadata, prompb.MetricMetadata{
+			Type:             metadataTypes[mf.GetType()],
+			MetricFamilyName: mf.GetName(),
+			Help:             mf.GetHelp(),
+		})
+	}
+	sort.Slice(req.Metadata, func(i, j int) bool {
+		return req.Metadata[i].MetricFamilyName < req.Metadata[j].MetricFamilyName
+	})
+	return req
+}
@@ -0,0 +1,140 @@
+package push
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: test_counter_total
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1 2
+---
+spec:
+  name: test_histogram
+  type: histogram
+  labels:
+  - aaa
+  buckets: [1, 2]
+data:
+- labels:
+  - key: aaa
+    value: foo
+  observedValues:
+  - 0.5 1.5 3
+`
+
+// receiver is a stand-in of a remote-write receiver.
+type receiver struct {
+	requests []*prompb.WriteRequest
+	// The number of requests to fail with 503 before succeeding.
+	failures int32
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&rv.failures, -1) >= 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Test") != "value" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	compressed, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req prompb.WriteRequest
+	if err := req.Unmarshal(data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rv.requests = append(rv.requests, &req)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func seriesValues(req *prompb.WriteRequest) map[string]float64 {
+	result := make(map[string]float64)
+	for _, ts := range req.Timeseries {
+		key := ""
+		for _, l := range ts.Labels {
+			key += l.Name + "=" + l.Value + ","
+		}
+		result[key] = ts.Samples[0].Value
+	}
+	return result
+}
+
+func TestRemoteWrite(t *testing.T) {
+	rv := &receiver{failures: 1}
+	server := httptest.NewServer(rv)
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	require.NoError(t, e.Register([]byte(recipe)))
+
+	sink := &RemoteWriteSink{
+		URL: server.URL,
+		HTTPConfig: HTTPConfig{
+			Headers:      map[string]string{"X-Test": "value"},
+			Username:     "user",
+			Password:     "pass",
+			MaxRetries:   1,
+			RetryBackoff: time.Millisecond,
+		},
+	}
+	now := time.UnixMilli(1685577600000)
+	tick(context.Background(), e, registry, now, []Sink{sink})
+	tick(context.Background(), e, registry, now.Add(time.Minute), []Sink{sink})
+
+	// The first request is retried.
+	require.Len(t, rv.requests, 2)
+	assert.Equal(t, now.UnixMilli(), rv.requests[0].Timeseries[0].Samples[0].Timestamp)
+	assert.Equal(t, map[string]float64{
+		"__name__=test_counter_total,aaa=foo,":            1,
+		"__name__=test_histogram_bucket,aaa=foo,le=1,":    1,
+		"__name__=test_histogram_bucket,aaa=foo,le=2,":    2,
+		"__name__=test_histogram_bucket,aaa=foo,le=+Inf,": 3,
+		"__name__=test_histogram_sum,aaa=foo,":            5,
+		"__name__=test_histogram_count,aaa=foo,":          3,
+	}, seriesValues(rv.requests[0]))
+	assert.Equal(t, 3.0, seriesValues(rv.requests[1])["__name__=test_counter_total,aaa=foo,"])
+	assert.Equal(t, []prompb.MetricMetadata{
+		{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "test_counter_total"},
+		{Type: prompb.MetricMetadata_HISTOGRAM, MetricFamilyName: "test_histogram"},
+	}, rv.requests[1].Metadata)
+
+	// A client error is not retried.
+	sink.Username = ""
+	assert.Error(t, sink.Push(context.Background(), nil, now))
+	assert.Len(t, rv.requests, 2)
+}
@@ -0,0 +1,163 @@
+package queryrange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/common/model"
+)
+
+// response is the JSON response of the /api/v1/query_range API of Prometheus.
+type response struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// MaxSteps is the maximum number of the steps of the imported series,
+// which is the same as the maximum number of the points per series of the query_range API.
+const MaxSteps = 11000
+
+// minStep is the smallest step because the timestamps have millisecond precision.
+const minStep = time.Millisecond
+
+type point struct {
+	timestamp float64
+	value     float64
+}
+
+// ImportSeries parses the matrix result of a query_range response.
+// The samples are placed on the grid of step starting from the earliest sample,
+// and the grid points without a sample are returned as nil values.
+// If step is 0, the smallest interval between the samples is used as the step.
+// It is an error if the step is less than a millisecond or the grid has more than MaxSteps points.
+// If name is not empty, it is used as the metric name instead of __name__.
+func ImportSeries(data []byte, step time.Duration, name string) ([]exporter.Series, error) {
+	if step < 0 || (step > 0 && step < minStep) {
+		return nil, fmt.Errorf("invalid step: %v", step)
+	}
+
+	var resp response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", resp.Error)
+	}
+	if resp.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unsupported result type: %s", resp.Data.ResultType)
+	}
+	if len(resp.Data.Result) == 0 {
+		return nil, errors.New("no series is found")
+	}
+
+	points := make([][]point, len(resp.Data.Result))
+	start := math.Inf(1)
+	end := math.Inf(-1)
+	minInterval := math.Inf(1)
+	for i, r := range resp.Data.Result {
+		for _, v := range r.Values {
+			p, err := parsePoint(v)
+			if err != nil {
+				return nil, err
+			}
+			if n := len(points[i]); n != 0 {
+				interval := p.timestamp - points[i][n-1].timestamp
+				if interval <= 0 {
+					return nil, fmt.Errorf("samples are not in time order: %v", r.Metric)
+				}
+				minInterval = math.Min(minInterval, interval)
+			}
+			points[i] = append(points[i], p)
+			start = math.Min(start, p.timestamp)
+			end = math.Max(end, p.timestamp)
+		}
+	}
+	if math.IsInf(start, 1) {
+		return nil, errors.New("no sample is found")
+	}
+
+	stepSec := step.Seconds()
+	if stepSec <= 0 {
+		if math.IsInf(minInterval, 1) {
+			// Every series has a single sample.
+			minInterval = 1
+		}
+		stepSec = minInterval
+		if stepSec < minStep.Seconds() {
+			return nil, fmt.Errorf("samples are too close to each other: %gs", stepSec)
+		}
+	}
+	span := math.Round((end - start) / stepSec)
+	if span >= MaxSteps {
+		return nil, fmt.Errorf("too many steps: the samples span %gs with the step of %gs, which exceeds %d steps",
+			end-start, stepSec, MaxSteps)
+	}
+	steps := int(span) + 1
+
+	result := make([]exporter.Series, 0, len(resp.Data.Result))
+	for i, r := range resp.Data.Result {
+		labels := make(map[string]string)
+		for k, v := range r.Metric {
+			labels[k] = v
+		}
+		metricName := name
+		if metricName == "" {
+			metricName = labels[model.MetricNameLabel]
+		}
+		if metricName == "" {
+			return nil, fmt.Errorf("metric name not found: %v", r.Metric)
+		}
+		delete(labels, model.MetricNameLabel)
+
+		s := exporter.Series{
+			Name:   metricName,
+			Labels: labels,
+			Values: make([]*float64, steps),
+		}
+		for _, p := range points[i] {
+			p := p
+			s.Values[int(math.Round((p.timestamp-start)/stepSec))] = &p.value
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// parsePoint parses a sample such as [1435781451.781, "1"].
+func parsePoint(v [2]interface{}) (point, error) {
+	ts, ok := v[0].(float64)
+	if !ok {
+		return point{}, fmt.Errorf("invalid timestamp: %v", v[0])
+	}
+	s, ok := v[1].(string)
+	if !ok {
+		return point{}, fmt.Errorf("invalid value: %v", v[1])
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return point{}, err
+	}
+	return point{timestamp: ts, value: value}, nil
+}
+
+// Import converts the matrix result of a query_range response into recipes.
+// See ImportSeries and exporter.RecipesFromSeries for the details.
+func Import(data []byte, step time.Duration, name string) ([]byte, error) {
+	series, err := ImportSeries(data, step, name)
+	if err != nil {
+		return nil, err
+	}
+	return exporter.RecipesFromSeries(series)
+}
@@ -0,0 +1,109 @@
+package queryrange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const matrix = `{
+  "status": "success",
+  "data": {
+    "resultType": "matrix",
+    "result": [
+      {
+        "metric": {"__name__": "test_gauge", "aaa": "foo"},
+        "values": [[1700000000, "1"], [1700000015, "2"], [1700000045, "4"]]
+      },
+      {
+        "metric": {"__name__": "test_total", "aaa": "foo"},
+        "values": [[1700000015.000, "10"], [1700000030, "25"]]
+      }
+    ]
+  }
+}`
+
+func values(vs []*float64) []interface{} {
+	result := make([]interface{}, len(vs))
+	for i, v := range vs {
+		if v != nil {
+			result[i] = *v
+		}
+	}
+	return result
+}
+
+func TestImportSeries(t *testing.T) {
+	series, err := ImportSeries([]byte(matrix), 0, "")
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	assert.Equal(t, "test_gauge", series[0].Name)
+	assert.Equal(t, map[string]string{"aaa": "foo"}, series[0].Labels)
+	// the gaps become missing samples
+	assert.Equal(t, []interface{}{1.0, 2.0, nil, 4.0}, values(series[0].Values))
+	assert.Equal(t, []interface{}{nil, 10.0, 25.0, nil}, values(series[1].Values))
+
+	// explicit step and name
+	series, err = ImportSeries([]byte(matrix), 5*time.Second, "renamed")
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", series[0].Name)
+	assert.Len(t, series[0].Values, 10)
+}
+
+func TestImport(t *testing.T) {
+	recipes, err := Import([]byte(matrix), 0, "")
+	require.NoError(t, err)
+	assert.Contains(t, string(recipes), "sequence: 1 2 _ 4\n")
+	// the counter is converted into the deltas
+	assert.Contains(t, string(recipes), "sequence: _ 10 15 _\n")
+
+	cases := []struct {
+		desc string
+		data string
+	}{
+		{desc: "broken", data: `{"status": `},
+		{desc: "error", data: `{"status": "error", "error": "bad query"}`},
+		{desc: "vector", data: `{"status": "success", "data": {"resultType": "vector", "result": []}}`},
+		{desc: "empty", data: `{"status": "success", "data": {"resultType": "matrix", "result": []}}`},
+		{
+			desc: "no name",
+			data: `{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"aaa": "foo"}, "values": [[1700000000, "1"]]}]}}`,
+		},
+		{
+			desc: "invalid value",
+			data: `{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"__name__": "test", "aaa": "foo"}, "values": [[1700000000, 1]]}]}}`,
+		},
+		{
+			desc: "unordered",
+			data: `{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"__name__": "test", "aaa": "foo"}, "values": [[1700000015, "1"], [1700000000, "1"]]}]}}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := Import([]byte(tc.data), 0, "")
+			assert.Error(t, err)
+		})
+	}
+
+	// The grid of the steps is limited.
+	sparse := `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"__name__": "test", "aaa": "foo"}, "values": [[0, "1"], [0.001, "1"], [100000000, "1"]]}]}}`
+	_, err = Import([]byte(sparse), 0, "")
+	assert.Error(t, err)
+	_, err = Import([]byte(matrix), time.Nanosecond, "")
+	assert.Error(t, err)
+	_, err = Import([]byte(matrix), -time.Second, "")
+	assert.Error(t, err)
+	dense := `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"__name__": "test", "aaa": "foo"}, "values": [[0, "1"], [0.0001, "1"]]}]}}`
+	_, err = Import([]byte(dense), 0, "")
+	assert.Error(t, err)
+	// The steps up to the limit are accepted.
+	_, err = Import([]byte(sparse), time.Duration(100000000/(MaxSteps-1)+1)*time.Second, "")
+	assert.NoError(t, err)
+}
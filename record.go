@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/peng225/any-exporter/record"
+)
+
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	url := fs.String("url", "", "URL of the metrics to record such as http://localhost:9100/metrics")
+	count := fs.Int("count", 10, "number of scrapes")
+	interval := fs.Duration("interval", 15*time.Second, "interval between the scrapes")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of a scrape")
+	output := fs.String("output", "-", "output file ('-' for the standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *url == "" {
+		return errors.New("no URL is specified")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	client := &http.Client{
+		Timeout: *timeout,
+	}
+	recipes, err := record.Record(ctx, client, *url, *count, *interval)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, recipes)
+}
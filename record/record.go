@@ -0,0 +1,77 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Scrape fetches the metrics from the target URL in the Prometheus text format.
+func Scrape(ctx context.Context, client *http.Client, url string) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+	req.Header.Set("User-Agent", "any-exporter")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	mfs := make([]*dto.MetricFamily, 0, len(families))
+	for _, mf := range families {
+		mfs = append(mfs, mf)
+	}
+	sort.Slice(mfs, func(i, j int) bool {
+		return mfs[i].GetName() < mfs[j].GetName()
+	})
+	return mfs, nil
+}
+
+// Record scrapes the target count times every interval and builds the recipes
+// which replay the scraped metrics. See exporter.RecipesFromScrapes for the details.
+func Record(ctx context.Context, client *http.Client, url string, count int, interval time.Duration) ([]byte, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid number of scrapes: %d", count)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval: %v", interval)
+	}
+
+	scrapes := make([][]*dto.MetricFamily, 0, count)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		mfs, err := Scrape(ctx, client, url)
+		if err != nil {
+			return nil, err
+		}
+		scrapes = append(scrapes, mfs)
+		if len(scrapes) == count {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return exporter.RecipesFromScrapes(scrapes)
+}
@@ -0,0 +1,123 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exposition is the output of the target at each scrape.
+var exposition = []string{
+	`# TYPE test_requests_total counter
+test_requests_total{code="200"} 10
+# TYPE test_temperature gauge
+test_temperature{room="a"} 20.5
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{handler="/",le="0.1"} 1
+test_latency_seconds_bucket{handler="/",le="1"} 3
+test_latency_seconds_bucket{handler="/",le="+Inf"} 3
+test_latency_seconds_sum{handler="/"} 1.05
+test_latency_seconds_count{handler="/"} 3
+# TYPE test_up gauge
+test_up 1
+# TYPE test_summary summary
+test_summary{quantile="0.5"} 1
+test_summary_sum 1
+test_summary_count 1
+`,
+	`# TYPE test_requests_total counter
+test_requests_total{code="200"} 15
+test_requests_total{code="500"} 1
+# TYPE test_temperature gauge
+test_temperature{room="a"} 19
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{handler="/",le="0.1"} 1
+test_latency_seconds_bucket{handler="/",le="1"} 3
+test_latency_seconds_bucket{handler="/",le="+Inf"} 5
+test_latency_seconds_sum{handler="/"} 7.05
+test_latency_seconds_count{handler="/"} 5
+`,
+}
+
+func TestRecord(t *testing.T) {
+	scrapes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, exposition[scrapes%len(exposition)])
+		scrapes++
+	}))
+	defer server.Close()
+
+	recipes, err := Record(context.Background(), server.Client(), server.URL, 2, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 2, scrapes)
+
+	// replay the recorded metrics
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	require.NoError(t, e.Register(recipes))
+
+	for step := 0; step < 2; step++ {
+		e.Update()
+		mfs, err := registry.Gather()
+		require.NoError(t, err)
+		values := make(map[string]float64)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				name := mf.GetName()
+				for _, lp := range m.GetLabel() {
+					name += "," + lp.GetName() + "=" + lp.GetValue()
+				}
+				values[name] = m.GetCounter().GetValue() + m.GetGauge().GetValue()
+				if h := m.GetHistogram(); h != nil {
+					values[name+",count"] = float64(h.GetSampleCount())
+					values[name+",sum"] = h.GetSampleSum()
+					for _, b := range h.GetBucket() {
+						values[fmt.Sprintf("%s,le=%g", name, b.GetUpperBound())] = float64(b.GetCumulativeCount())
+					}
+				}
+			}
+		}
+
+		switch step {
+		case 0:
+			assert.Equal(t, 10.0, values["test_requests_total,code=200"])
+			assert.NotContains(t, values, "test_requests_total,code=500")
+			assert.Equal(t, 20.5, values["test_temperature,room=a"])
+			assert.Equal(t, 1.0, values["test_latency_seconds,handler=/,le=0.1"])
+			assert.Equal(t, 3.0, values["test_latency_seconds,handler=/,le=1"])
+			assert.Equal(t, 3.0, values["test_latency_seconds,handler=/,count"])
+			assert.InDelta(t, 1.05, values["test_latency_seconds,handler=/,sum"], 1e-9)
+			assert.NotContains(t, values, "test_summary")
+			// the metrics without labels are skipped
+			assert.NotContains(t, values, "test_up")
+		case 1:
+			assert.Equal(t, 15.0, values["test_requests_total,code=200"])
+			assert.Equal(t, 1.0, values["test_requests_total,code=500"])
+			assert.Equal(t, 19.0, values["test_temperature,room=a"])
+			assert.Equal(t, 3.0, values["test_latency_seconds,handler=/,le=1"])
+			assert.Equal(t, 5.0, values["test_latency_seconds,handler=/,count"])
+			// the +Inf bucket cannot hold a value larger than twice of the largest bucket
+			assert.InDelta(t, 5.05, values["test_latency_seconds,handler=/,sum"], 1e-9)
+		}
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := Record(context.Background(), server.Client(), server.URL, 2, time.Millisecond)
+	assert.Error(t, err)
+	_, err = Record(context.Background(), server.Client(), server.URL, 0, time.Millisecond)
+	assert.Error(t, err)
+}
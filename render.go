@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/peng225/any-exporter/loader"
+	"github.com/peng225/any-exporter/render"
+	"github.com/prometheus/common/expfmt"
+)
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: render [flags] <recipe>...")
+		fs.PrintDefaults()
+	}
+	steps := fs.Int("steps", 0, "number of scrapes to render (0 renders until all of the sequences are exhausted)")
+	interval := fs.Duration("interval", 15*time.Second, "interval between the scrapes used to resolve the relative timestamps")
+	table := fs.Bool("table", false, "print a compact table with a row per series and a column per step")
+	openMetrics := fs.Bool("openmetrics", false, "print the OpenMetrics format, in which exemplars are exposed")
+	patterns, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(patterns) == 0 {
+		return errors.New("no recipe is specified")
+	}
+	data, err := loader.ReadAll(patterns)
+	if err != nil {
+		return err
+	}
+
+	if *table {
+		return render.WriteTable(os.Stdout, data, *steps)
+	}
+	format := expfmt.FmtText
+	if *openMetrics {
+		format = expfmt.FmtOpenMetrics_1_0_0
+	}
+	return render.WriteExposition(os.Stdout, data, time.Now(), *interval, *steps, format)
+}
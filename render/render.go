@@ -0,0 +1,78 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/promtool"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// WriteExposition simulates the recipes and writes what a scrape gets at each step
+// in the given exposition format, preceded by a comment line with the step number (1-origin).
+// The relative timestamps in the recipes are resolved as if the scrapes happened every interval from start.
+// If steps is 0, the simulation runs until all of the sequences are exhausted.
+func WriteExposition(w io.Writer, recipes [][]byte, start time.Time, interval time.Duration,
+	steps int, format expfmt.Format) error {
+	step := 0
+	return exporter.Simulate(recipes, start, interval, steps, func(now time.Time, mfs []*dto.MetricFamily) error {
+		step++
+		if step != 1 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# step %d\n", step); err != nil {
+			return err
+		}
+		enc := expfmt.NewEncoder(w, format)
+		for _, mf := range mfs {
+			if err := enc.Encode(mf); err != nil {
+				return err
+			}
+		}
+		// The OpenMetrics encoder writes the "# EOF" line on Close.
+		if closer, ok := enc.(expfmt.Closer); ok {
+			return closer.Close()
+		}
+		return nil
+	})
+}
+
+// WriteTable simulates the recipes and writes a table which has a row per series
+// and a column per step (1-origin). The values are the ones exposed by the exporter,
+// and '_' means the series is absent at the step. See promtool.Render for the details.
+func WriteTable(w io.Writer, recipes [][]byte, steps int) error {
+	tg, err := promtool.Render(recipes, time.Minute, steps)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	columns := 0
+	rows := make([][]string, 0, len(tg.InputSeries))
+	for _, s := range tg.InputSeries {
+		values := strings.Fields(s.Values)
+		if len(values) > columns {
+			columns = len(values)
+		}
+		rows = append(rows, append([]string{s.Series}, values...))
+	}
+
+	header := []string{"SERIES"}
+	for i := 1; i <= columns; i++ {
+		header = append(header, strconv.Itoa(i))
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: test_counter_total
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1+1x2
+---
+spec:
+  name: test_gauge
+  type: gauge
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 5 _ 1
+`
+
+func TestWriteExposition(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteExposition(&buf, [][]byte{[]byte(recipe)}, time.Unix(0, 0), time.Minute, 2, expfmt.FmtText))
+	assert.Equal(t, `# step 1
+# HELP test_counter_total 
+# TYPE test_counter_total counter
+test_counter_total{aaa="foo"} 1
+# HELP test_gauge 
+# TYPE test_gauge gauge
+test_gauge{aaa="foo"} 5
+
+# step 2
+# HELP test_counter_total 
+# TYPE test_counter_total counter
+test_counter_total{aaa="foo"} 3
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteExposition(&buf, [][]byte{[]byte(recipe)}, time.Unix(0, 0), time.Minute, 1, expfmt.FmtOpenMetrics_1_0_0))
+	assert.Equal(t, `# step 1
+# HELP test_counter 
+# TYPE test_counter counter
+test_counter_total{aaa="foo"} 1.0
+# HELP test_gauge 
+# TYPE test_gauge gauge
+test_gauge{aaa="foo"} 5.0
+# EOF
+`, buf.String())
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteTable(&buf, [][]byte{[]byte(recipe)}, 0))
+	assert.Equal(t, `SERIES                         1  2  3
+test_counter_total{aaa="foo"}  1  3  6
+test_gauge{aaa="foo"}          5  _  1
+`, buf.String())
+}
+
+func TestInvalidRecipe(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, WriteExposition(&buf, [][]byte{[]byte("invalid")}, time.Unix(0, 0), time.Minute, 1, expfmt.FmtText))
+	assert.Error(t, WriteTable(&buf, [][]byte{[]byte("invalid")}, 1))
+}
@@ -0,0 +1,90 @@
+package sample
+
+import (
+	"math"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// Sample is a single value of a series as it appears in the text exposition.
+// A histogram is flattened into the _bucket, _sum and _count series, for example.
+type Sample struct {
+	// Labels include the metric name.
+	Labels labels.Labels
+	Value  float64
+	// TimestampMs is the explicit timestamp of the sample. Zero means no explicit timestamp.
+	TimestampMs int64
+}
+
+// FromMetricFamilies flattens the gathered metric families into samples.
+func FromMetricFamilies(mfs []*dto.MetricFamily) []Sample {
+	result := make([]Sample, 0)
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			add := func(suffix string, value float64, extra ...string) {
+				b := labels.NewScratchBuilder(len(m.GetLabel()) + 2)
+				b.Add(model.MetricNameLabel, name+suffix)
+				for _, lp := range m.GetLabel() {
+					b.Add(lp.GetName(), lp.GetValue())
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					b.Add(extra[i], extra[i+1])
+				}
+				b.Sort()
+				result = append(result, Sample{
+					Labels:      b.Labels(),
+					Value:       value,
+					TimestampMs: m.GetTimestampMs(),
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						infSeen = true
+					}
+					add("_bucket", float64(b.GetCumulativeCount()),
+						model.BucketLabel, formatFloat(b.GetUpperBound()))
+				}
+				if !infSeen {
+					add("_bucket", float64(h.GetSampleCount()), model.BucketLabel, "+Inf")
+				}
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), model.QuantileLabel, formatFloat(q.GetQuantile()))
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			default:
+				add("", m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return result
+}
+
+// formatFloat formats a float in the same way as the text exposition.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, +1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/peng225/any-exporter/eval"
+	"github.com/peng225/any-exporter/loader"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	var ruleFiles, recipes stringsFlag
+	fs.Var(&ruleFiles, "rules", "Prometheus rule file (can be repeated)")
+	fs.Var(&recipes, "recipe", "recipe file, directory or glob pattern to simulate (can be repeated)")
+	interval := fs.Duration("interval", time.Minute, "scrape and rule evaluation interval")
+	steps := fs.Int("steps", 0, "number of steps to simulate (0 simulates until all of the sequences are exhausted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(recipes) == 0 {
+		return errors.New("no recipe is specified")
+	}
+	data, err := loader.ReadAll(recipes)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "any-exporter-simulate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	events, err := eval.SimulateRules(context.Background(), dir, data, ruleFiles, *interval, *steps)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		fmt.Println("no alert became active")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tTIME\tSTATE\tALERT\tVALUE")
+	for _, e := range events {
+		alert := e.Name + labels.NewBuilder(e.Labels).Del(labels.AlertName).Labels().String()
+		fmt.Fprintf(w, "%d\t%v\t%s\t%s\t%s\n", e.Step, e.Time.Sub(eval.Start), e.State, alert,
+			strconv.FormatFloat(e.Value, 'g', -1, 64))
+	}
+	return w.Flush()
+}
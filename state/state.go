@@ -0,0 +1,97 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/peng225/any-exporter/exporter"
+)
+
+// Saver persists the state of an exporter to a file.
+type Saver struct {
+	exporter *exporter.Exporter
+	path     string
+	interval time.Duration
+	// The generation of the exporter saved last time.
+	savedGeneration uint64
+	mu              sync.Mutex
+}
+
+func NewSaver(e *exporter.Exporter, path string, interval time.Duration) *Saver {
+	return &Saver{
+		exporter: e,
+		path:     path,
+		interval: interval,
+	}
+}
+
+// Restore restores the exporter from the state file.
+// It does nothing if the state file does not exist.
+func (s *Saver) Restore() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("state file %s does not exist", s.path)
+			return nil
+		}
+		return err
+	}
+	return s.exporter.Restore(data)
+}
+
+// Save writes the state of the exporter to the state file if it has changed since the last save.
+func (s *Saver) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	generation := s.exporter.Generation()
+	if generation == s.savedGeneration {
+		return nil
+	}
+	data, err := s.exporter.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so that a crash never leaves a broken state file.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.savedGeneration = generation
+	return nil
+}
+
+// Run saves the state periodically until ctx is done.
+func (s *Saver) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
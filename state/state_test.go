@@ -0,0 +1,55 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recipe = `spec:
+  name: test
+  type: counter
+  labels:
+  - aaa
+data:
+- labels:
+  - key: aaa
+    value: foo
+  sequence: 1x10
+`
+
+func TestSaver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+
+	e := exporter.New(prometheus.NewRegistry())
+	s := NewSaver(e, path, 0)
+	// no state file yet
+	require.NoError(t, s.Restore())
+	// nothing to save
+	require.NoError(t, s.Save())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, e.Register([]byte(recipe)))
+	e.Update()
+	require.NoError(t, s.Save())
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	restored := exporter.New(registry)
+	require.NoError(t, NewSaver(restored, path, 0).Restore())
+	mfs, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	assert.Equal(t, 1.0, mfs[0].GetMetric()[0].GetCounter().GetValue())
+
+	snapshot, err := restored.Snapshot()
+	require.NoError(t, err)
+	assert.Equal(t, string(saved), string(snapshot))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/peng225/any-exporter/eval"
+	"github.com/peng225/any-exporter/loader"
+)
+
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	var recipes stringsFlag
+	fs.Var(&recipes, "recipe", "recipe file, directory or glob pattern with the expectations (can be repeated)")
+	interval := fs.Duration("interval", time.Minute, "scrape interval of the simulated metrics")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(recipes) == 0 {
+		return errors.New("no recipe is specified")
+	}
+	data, err := loader.ReadAll(recipes)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "any-exporter-test-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	results, err := eval.Run(context.Background(), dir, data, *interval)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("FAIL: step %d: %s\n%v\n", r.Expectation.Step, r.Expectation.Expr, r.Err)
+		} else {
+			fmt.Printf("PASS: step %d: %s\n", r.Expectation.Step, r.Expectation.Expr)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(results)-failed, failed)
+	if failed != 0 {
+		return fmt.Errorf("%d expectation(s) failed", failed)
+	}
+	return nil
+}
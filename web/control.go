@@ -0,0 +1,74 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/peng225/any-exporter/exporter"
+)
+
+// ControlHandler serves pause, resume, step, rewind and reset. The request path must be the operation,
+// so the handler is expected to be wrapped by http.StripPrefix.
+// The operations apply to the metrics specified by the name parameters, or to all of them.
+type ControlHandler struct {
+	Exporter *exporter.Exporter
+}
+
+func (h ControlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("invalid method: %s", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	names := r.URL.Query()["name"]
+	var err error
+	switch op := r.URL.Path; op {
+	case "pause":
+		err = h.Exporter.Pause(names...)
+	case "resume":
+		err = h.Exporter.Resume(names...)
+	case "reset":
+		err = h.Exporter.Reset(names...)
+	case "step":
+		n := 1
+		if v := r.URL.Query().Get("n"); v != "" {
+			n, err = strconv.Atoi(v)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		err = h.Exporter.Step(n, names...)
+	case "rewind":
+		var to int
+		to, err = strconv.Atoi(r.URL.Query().Get("to"))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		err = h.Exporter.Rewind(to, names...)
+	default:
+		log.Printf("invalid control operation: %s", op)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, exporter.NotFoundErr) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	log.Println("control request completed successfully")
+
+	w.WriteHeader(http.StatusOK)
+}
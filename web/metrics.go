@@ -7,10 +7,15 @@ import (
 )
 
 type MetricsHandler struct {
+	Exporter     *exporter.Exporter
 	ChildHandler http.Handler
+	// DisableUpdate stops scraping from advancing the sequences.
+	DisableUpdate bool
 }
 
 func (h MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	exporter.Update()
+	if !h.DisableUpdate {
+		h.Exporter.Update()
+	}
 	h.ChildHandler.ServeHTTP(w, r)
 }
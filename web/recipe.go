@@ -1,44 +1,192 @@
 package web
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/peng225/any-exporter/exporter"
+	"github.com/peng225/any-exporter/promtool"
+	"github.com/peng225/any-exporter/queryrange"
 )
 
-func RecipeHandler(w http.ResponseWriter, r *http.Request) {
+type RecipeHandler struct {
+	Exporter *exporter.Exporter
+	// MaxBodySize limits the size of a posted recipe in bytes. Zero means no limit.
+	MaxBodySize int64
+}
+
+func (h RecipeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
+	case http.MethodGet:
+		h.RecipeGetHandler(w, r)
 	case http.MethodPost:
-		RecipePostHandler(w, r)
+		h.RecipePostHandler(w, r)
 	case http.MethodDelete:
-		RecipeDeleteHandler(w, r)
+		h.RecipeDeleteHandler(w, r)
 	default:
 		log.Printf("invalid method: %s", r.Method)
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
-func RecipePostHandler(w http.ResponseWriter, r *http.Request) {
+func (h RecipeHandler) RecipeGetHandler(w http.ResponseWriter, r *http.Request) {
+	recipes, err := h.Exporter.Recipes()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "yaml":
+		for i, recipe := range recipes {
+			if i != 0 {
+				buf.WriteString("---\n")
+			}
+			buf.Write(recipe)
+		}
+	case "promtool":
+		interval := time.Minute
+		if v := r.URL.Query().Get("interval"); v != "" {
+			interval, err = time.ParseDuration(v)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		steps := 0
+		if v := r.URL.Query().Get("steps"); v != "" {
+			steps, err = strconv.Atoi(v)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		if len(recipes) != 0 {
+			if err := promtool.WriteTestGroup(&buf, recipes, interval, steps); err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+	default:
+		log.Printf("invalid format: %s", format)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Println(err)
+	}
+}
+
+func (h RecipeHandler) RecipePostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Body == nil {
 		log.Println("request body is nil")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
+	if h.MaxBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.MaxBodySize)
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "yaml"
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+			switch mediaType {
+			case "application/json":
+				format = "json"
+			case "text/csv":
+				format = "csv"
+			}
+		}
+	}
+
+	if format == "csv" {
+		// A CSV recipe is parsed while it is read, so that a large one does not have to be buffered.
+		h.writeRegisterResult(w, h.Exporter.RegisterCSV(r.Body))
+		return
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	register := h.Exporter.Register
+	switch format {
+	case "yaml":
+	case "json":
+		register = h.Exporter.RegisterJSON
+	case "promtool":
+		group := -1
+		if v := r.URL.Query().Get("group"); v != "" {
+			group, err = strconv.Atoi(v)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		body, err = promtool.Import(body, group)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	case "query_range":
+		var step time.Duration
+		if v := r.URL.Query().Get("step"); v != "" {
+			step, err = time.ParseDuration(v)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		body, err = queryrange.Import(body, step, r.URL.Query().Get("name"))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	default:
+		log.Printf("invalid format: %s", format)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	err = exporter.Register(body)
+	h.writeRegisterResult(w, register(body))
+}
+
+func (h RecipeHandler) writeRegisterResult(w http.ResponseWriter, err error) {
 	if err != nil {
 		log.Println(err)
-		if errors.Is(err, exporter.ConflictErr) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else if errors.Is(err, exporter.ConflictErr) {
 			w.WriteHeader(http.StatusConflict)
 		} else {
 			w.WriteHeader(http.StatusBadRequest)
@@ -51,11 +199,87 @@ func RecipePostHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func RecipeDeleteHandler(w http.ResponseWriter, r *http.Request) {
+func (h RecipeHandler) RecipeDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	force := r.URL.Query().Get("force") == "true"
-	exporter.Clear(force)
+	h.Exporter.Clear(force)
 
 	log.Println("recipe delete request completed successfully")
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// RecipeItemHandler serves the recipe of a metrics. The request path must be the metrics name,
+// so the handler is expected to be wrapped by http.StripPrefix.
+type RecipeItemHandler struct {
+	Exporter *exporter.Exporter
+	// MaxBodySize limits the size of the appended data in bytes. Zero means no limit.
+	MaxBodySize int64
+}
+
+func (h RecipeItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path
+	if name == "" || strings.Contains(name, "/") {
+		log.Printf("invalid metrics name: %s", name)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		if h.Exporter.Delete(name) == 0 {
+			log.Printf("metrics not found: %s", name)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Println("recipe delete request completed successfully")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		h.patch(w, r, name)
+	default:
+		log.Printf("invalid method: %s", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// patch appends the data in the request body to the recipe of the metrics.
+func (h RecipeItemHandler) patch(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Body == nil {
+		log.Println("request body is nil")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if h.MaxBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.MaxBodySize)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println(err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	appendData := h.Exporter.Append
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "application/json" {
+		appendData = h.Exporter.AppendJSON
+	}
+	if err := appendData(name, body); err != nil {
+		log.Println(err)
+		if errors.Is(err, exporter.NotFoundErr) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	log.Println("recipe patch request completed successfully")
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/peng225/any-exporter/exporter"
+)
+
+// Status is the response of /status.
+type Status struct {
+	Metrics []exporter.MetricsStatus `json:"metrics"`
+	// Exhausted is true if metrics are registered and all of them have no data to export anymore.
+	Exhausted bool `json:"exhausted"`
+	// Paused is true if all of the metrics are paused.
+	Paused bool `json:"paused"`
+}
+
+type StatusHandler struct {
+	Exporter *exporter.Exporter
+}
+
+func (h StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("invalid method: %s", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := Status{
+		Metrics: h.Exporter.Status(),
+		Paused:  h.Exporter.Paused(),
+	}
+	status.Exhausted = len(status.Metrics) != 0
+	for _, m := range status.Metrics {
+		if !m.Exhausted {
+			status.Exhausted = false
+		}
+	}
+
+	body, err := json.Marshal(&status)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
+	}
+}
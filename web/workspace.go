@@ -0,0 +1,152 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/peng225/any-exporter/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const WorkspacePrefix = "/ws/"
+
+type workspace struct {
+	recipeHandler     RecipeHandler
+	recipeItemHandler RecipeItemHandler
+	metricsHandler    MetricsHandler
+	statusHandler     StatusHandler
+	controlHandler    ControlHandler
+}
+
+func newWorkspace(maxRecipeSize int64, enableOpenMetrics bool) *workspace {
+	registry := prometheus.NewRegistry()
+	e := exporter.New(registry)
+	return &workspace{
+		recipeHandler: RecipeHandler{
+			Exporter:    e,
+			MaxBodySize: maxRecipeSize,
+		},
+		recipeItemHandler: RecipeItemHandler{
+			Exporter:    e,
+			MaxBodySize: maxRecipeSize,
+		},
+		metricsHandler: MetricsHandler{
+			Exporter: e,
+			ChildHandler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+				EnableOpenMetrics: enableOpenMetrics,
+			}),
+		},
+		statusHandler: StatusHandler{
+			Exporter: e,
+		},
+		controlHandler: ControlHandler{
+			Exporter: e,
+		},
+	}
+}
+
+// WorkspaceHandler serves /ws/{name}/recipe, /ws/{name}/recipe/{metrics},
+// /ws/{name}/metrics, /ws/{name}/status and /ws/{name}/control/{operation}.
+// Each workspace has its own registry and metrics, so that operations
+// on a workspace never affect the others.
+type WorkspaceHandler struct {
+	workspaces        map[string]*workspace
+	maxRecipeSize     int64
+	enableOpenMetrics bool
+	mu                sync.Mutex
+	// createMu serializes the recipe posts which create a workspace.
+	createMu sync.Mutex
+}
+
+// NewWorkspaceHandler returns a WorkspaceHandler whose workspaces accept recipes up to maxRecipeSize bytes.
+// If enableOpenMetrics is true, the metrics are exposed in the OpenMetrics format when it is requested.
+func NewWorkspaceHandler(maxRecipeSize int64, enableOpenMetrics bool) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaces:        make(map[string]*workspace),
+		maxRecipeSize:     maxRecipeSize,
+		enableOpenMetrics: enableOpenMetrics,
+	}
+}
+
+func (h *WorkspaceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, resource, ok := parseWorkspacePath(r.URL.Path)
+	if !ok {
+		log.Printf("invalid path: %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ws := h.lookup(name)
+	if ws == nil && resource == "recipe" && r.Method == http.MethodPost {
+		h.create(w, r, name)
+		return
+	}
+	if ws == nil {
+		log.Printf("workspace not found: %s", name)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case resource == "recipe":
+		ws.recipeHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(resource, "recipe/"):
+		http.StripPrefix(WorkspacePrefix+name+"/recipe/", ws.recipeItemHandler).ServeHTTP(w, r)
+	case resource == "metrics":
+		ws.metricsHandler.ServeHTTP(w, r)
+	case resource == "status":
+		ws.statusHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(resource, "control/"):
+		http.StripPrefix(WorkspacePrefix+name+"/control/", ws.controlHandler).ServeHTTP(w, r)
+	default:
+		log.Printf("invalid path: %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *WorkspaceHandler) lookup(name string) *workspace {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.workspaces[name]
+}
+
+// create serves the recipe post to a workspace which does not exist yet.
+// A workspace is created implicitly by the first recipe post,
+// and is kept only if the recipe is registered.
+func (h *WorkspaceHandler) create(w http.ResponseWriter, r *http.Request, name string) {
+	h.createMu.Lock()
+	defer h.createMu.Unlock()
+
+	// The workspace may have been created by another post in the meantime.
+	if ws := h.lookup(name); ws != nil {
+		ws.recipeHandler.ServeHTTP(w, r)
+		return
+	}
+
+	ws := newWorkspace(h.maxRecipeSize, h.enableOpenMetrics)
+	ws.recipeHandler.ServeHTTP(w, r)
+	if len(ws.recipeHandler.Exporter.Status()) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.workspaces[name] = ws
+	log.Printf("workspace %s was created", name)
+}
+
+func parseWorkspacePath(path string) (string, string, bool) {
+	if !strings.HasPrefix(path, WorkspacePrefix) {
+		return "", "", false
+	}
+	// The resource may have its own path such as recipe/{metrics}.
+	name, resource, ok := strings.Cut(strings.TrimPrefix(path, WorkspacePrefix), "/")
+	if !ok || name == "" {
+		return "", "", false
+	}
+	return name, resource, true
+}